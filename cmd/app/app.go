@@ -1,73 +1,145 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 
-	"github.com/gorilla/mux"
-	"github.com/joho/godotenv"
+	"gitlab.com/vitbog/titov-rest/internal/db"
+	"gitlab.com/vitbog/titov-rest/internal/migrate"
 	"gitlab.com/vitbog/titov-rest/internal/server"
 )
 
 func main() {
-	fmt.Println("hello world")
+	loader := server.NewConfigLoader(flag.CommandLine)
+	printConfig := flag.Bool("print-config", false, "print the effective config with secrets redacted and exit")
+	flag.Parse()
 
-	cfgFile := server.ConfigFile{}
-	raw, err := os.ReadFile("./cfg/app.json")
+	cfgFile, err := loader.Load()
 	if err != nil {
-		log.Fatalf("Error reading config file: %s\n", err)
+		fatal("loading config", err)
 	}
-	err = json.Unmarshal(raw, &cfgFile)
-	if err != nil {
-		log.Fatalf("Error unmarshalling config: %s\n", err)
+	if *printConfig {
+		raw, err := json.MarshalIndent(cfgFile.Redacted(), "", "\t")
+		if err != nil {
+			fatal("printing config", err)
+		}
+		fmt.Println(string(raw))
+		return
 	}
 	cfg, err := server.ConfigConvert(cfgFile)
 	if err != nil {
-		log.Fatalf("Error converting config: %s\n", err)
+		fatal("invalid config", err)
 	}
 
-	err = godotenv.Load()
+	s := &server.Server{Config: cfg}
+	err = s.SetupLogger()
 	if err != nil {
-		log.Fatal("Error loading .env file")
+		fatal("setting up logging", err)
 	}
-
-	pgConnectionString := fmt.Sprintf("host=%s user=%s dbname=%s password=%s port=%s sslmode=disable", os.Getenv("DB_HOST"), os.Getenv("DB_USER"), os.Getenv("DB_NAME"), os.Getenv("DB_PASSWORD"), os.Getenv("DB_PORT"))
-
-	s := &server.Server{Config: cfg}
-	err = s.SetupDb(pgConnectionString)
+	err = s.SetupTracing()
+	if err != nil {
+		fatal("setting up tracing", err)
+	}
+	err = s.SetupValidator()
 	if err != nil {
-		log.Fatalf("Error connecting db: %s\n", err)
+		fatal("setting up validation", err)
+	}
+	err = s.SetupDb()
+	if err != nil {
+		fatal("connecting db", err)
+	}
+	if flag.Arg(0) == "migrate" {
+		err = runMigrate(s, flag.Args()[1:])
+		if err != nil {
+			fatal("migrating", err)
+		}
+		return
+	}
+	if pg, ok := s.Db.(*db.Db); ok && s.AutoMigrate {
+		migrator, err := migrate.New(&pg.Pg)
+		if err != nil {
+			fatal("loading migrations", err)
+		}
+		applied, err := migrator.Up(context.Background())
+		if err != nil {
+			fatal("migrating", err)
+		}
+		slog.Info("applied migrations", "count", len(applied))
+	}
+	err = s.SetupMetrics()
+	if err != nil {
+		fatal("setting up metrics", err)
+	}
+	err = s.SetupHealth()
+	if err != nil {
+		fatal("setting up health checks", err)
+	}
+	err = s.SetupEvents(s.PgConnectionString)
+	if err != nil {
+		fatal("listening for events", err)
+	}
+	err = s.SetupIdempotency()
+	if err != nil {
+		fatal("setting up idempotency keys", err)
+	}
+	err = s.SetupSync()
+	if err != nil {
+		fatal("setting up sync", err)
+	}
+	err = s.SetupGRPC()
+	if err != nil {
+		fatal("setting up gRPC", err)
+	}
+	err = s.SetupGraphQL()
+	if err != nil {
+		fatal("setting up GraphQL", err)
 	}
 
-	r := mux.NewRouter()
-	r.HandleFunc("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Write([]byte("hello"))
-		w.WriteHeader(http.StatusOK)
-	}))
+	r := routes(s)
 
-	r.Handle("/auth", http.HandlerFunc(s.Auth)).Methods(http.MethodPost)
-	r.Handle("/register", http.HandlerFunc(s.Register)).Methods(http.MethodPost)
+	s.SetupHTTP(s.HTTPAddress, r)
 
-	r.Handle("/tasks/create", s.Middleware(http.HandlerFunc(s.HandlerTasksCreate))).Methods(http.MethodPost)
-	r.Handle("/tasks/list", s.Middleware(http.HandlerFunc(s.HandlerTasks))).Methods(http.MethodPost)
-	r.Handle("/tasks/update/{id_task}", s.Middleware(http.HandlerFunc(s.HandlerTasksUpdate))).Methods(http.MethodPut)
-	r.Handle("/tasks/delete", s.Middleware(http.HandlerFunc(s.HandlerTasksDelete))).Methods(http.MethodDelete)
-	r.Handle("/tasks/csv", s.Middleware(http.HandlerFunc(s.HandlerTasksCSV))).Methods(http.MethodPost)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	r.Handle("/comments/create/{id_task}", s.Middleware(http.HandlerFunc(s.HandlerCommentsCreate))).Methods(http.MethodPost)
-	r.Handle("/comments/list/{id_task}", s.Middleware(http.HandlerFunc(s.HandlerComments))).Methods(http.MethodPost)
-	r.Handle("/comments/update/{id_comment}", s.Middleware(http.HandlerFunc(s.HandlerCommentsUpdate))).Methods(http.MethodPut)
-	r.Handle("/comments/delete/{id_comment}", s.Middleware(http.HandlerFunc(s.HandlerCommentsDelete))).Methods(http.MethodDelete)
+	go func() {
+		slog.Info("starting gRPC server", "address", s.GRPCAddress)
+		err := s.RunGRPC(s.GRPCAddress)
+		if err != nil {
+			fatal("serving gRPC", err)
+		}
+	}()
 
-	s.SetupHTTP("0.0.0.0:8080", r)
+	go func() {
+		slog.Info("starting server", "address", s.HTTPAddress)
+		err := s.Run()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatal("serving HTTP", err)
+		}
+	}()
 
-	fmt.Println("Starting server...")
-	s.Run()
+	<-ctx.Done()
+	stop()
+	slog.Info("shutting down")
 
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.ShutdownTimeout)
+	defer cancel()
+	err = s.Shutdown(shutdownCtx)
 	if err != nil {
-		fmt.Printf("%s\n", err.Error())
+		fatal("shutting down", err)
 	}
 }
+
+// fatal logs err and exits.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"gitlab.com/vitbog/titov-rest/internal/db"
+	"gitlab.com/vitbog/titov-rest/internal/migrate"
+	"gitlab.com/vitbog/titov-rest/internal/server"
+)
+
+const migrateUsage = `usage: app [flags] migrate up|down|status
+
+  up              apply every pending migration
+  down [-steps N] revert the last N applied migrations (default 1)
+  status          list the migrations and when they were applied`
+
+// runMigrate serves the migrate subcommand; args are what follows "migrate".
+func runMigrate(s *server.Server, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	pg, ok := s.Db.(*db.Db)
+	if !ok {
+		return errors.New("migrations only apply to postgres storage")
+	}
+	migrator, err := migrate.New(&pg.Pg)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			fmt.Printf("applied %d.%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		}
+		return err
+	case "down":
+		flags := flag.NewFlagSet("down", flag.ContinueOnError)
+		steps := flags.Int("steps", 1, "number of migrations to revert")
+		err := flags.Parse(args[1:])
+		if err != nil {
+			return err
+		}
+		if *steps < 1 {
+			return errors.New("-steps must be at least 1")
+		}
+		reverted, err := migrator.Down(ctx, *steps)
+		for _, m := range reverted {
+			fmt.Printf("reverted %d.%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(reverted) == 0 {
+			fmt.Println("no applied migrations")
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, st := range statuses {
+			appliedAt := "pending"
+			if st.Applied() {
+				appliedAt = st.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", st.Version, st.Name, appliedAt)
+		}
+		return w.Flush()
+	}
+
+	return errors.New(migrateUsage)
+}
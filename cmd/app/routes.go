@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/swaggest/swgui/v5emb"
+	"gitlab.com/vitbog/titov-rest/internal/openapi"
+	"gitlab.com/vitbog/titov-rest/internal/server"
+)
+
+// routes registers every HTTP route of s with its middleware. The routes must
+// match the OpenAPI document, see openapi.Verify.
+func routes(s *server.Server) *mux.Router {
+	r := mux.NewRouter()
+	r.Use(s.RequestID)
+	r.Use(s.Trace)
+	r.Use(s.AccessLog)
+	r.Use(s.InstrumentHTTP)
+	r.Use(s.LimitBody)
+	r.Use(s.Deadline)
+	r.NotFoundHandler = s.RequestID(s.Trace(s.AccessLog(s.InstrumentHTTP(http.HandlerFunc(s.HandlerNotFound)))))
+	r.MethodNotAllowedHandler = s.RequestID(s.Trace(s.AccessLog(s.InstrumentHTTP(http.HandlerFunc(s.HandlerMethodNotAllowed)))))
+	r.HandleFunc("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("hello"))
+	}))
+	r.Handle("/healthz", http.HandlerFunc(s.HandlerHealthz)).Methods(http.MethodGet)
+	r.Handle("/readyz", http.HandlerFunc(s.HandlerReadyz)).Methods(http.MethodGet)
+
+	r.Handle("/openapi.json", http.HandlerFunc(s.HandlerOpenAPI)).Methods(http.MethodGet)
+	r.Handle("/metrics", s.Metrics.Handler()).Methods(http.MethodGet).Name(openapi.Undocumented)
+	r.PathPrefix("/docs/").Handler(v5emb.New("titov-rest API", "/openapi.json", "/docs/")).Methods(http.MethodGet).Name(openapi.Undocumented)
+
+	r.Handle("/auth", http.HandlerFunc(s.Auth)).Methods(http.MethodPost)
+	r.Handle("/register", s.Idempotency(http.HandlerFunc(s.Register))).Methods(http.MethodPost)
+
+	r.Handle("/tasks/create", s.Middleware(s.Idempotency(http.HandlerFunc(s.HandlerTasksCreate)))).Methods(http.MethodPost)
+	r.Handle("/tasks/{id_task:[0-9]+}", s.Middleware(http.HandlerFunc(s.HandlerTask))).Methods(http.MethodGet)
+	r.Handle("/tasks/list", s.Middleware(http.HandlerFunc(s.HandlerTasks))).Methods(http.MethodPost)
+	r.Handle("/tasks/update/{id_task}", s.Middleware(http.HandlerFunc(s.HandlerTasksUpdate))).Methods(http.MethodPut)
+	r.Handle("/tasks/delete", s.Middleware(http.HandlerFunc(s.HandlerTasksDelete))).Methods(http.MethodDelete)
+	r.Handle("/tasks/batch", s.Middleware(http.HandlerFunc(s.HandlerTasksBatch))).Methods(http.MethodPost)
+	r.Handle("/tasks/csv", s.Middleware(http.HandlerFunc(s.HandlerTasksCSV))).Methods(http.MethodPost)
+	r.Handle("/tasks/export", s.Middleware(http.HandlerFunc(s.HandlerTasksExport))).Methods(http.MethodPost)
+	r.Handle("/tasks/import", s.Middleware(http.HandlerFunc(s.HandlerTasksImport))).Methods(http.MethodPost)
+
+	r.Handle("/comments/create/{id_task}", s.Middleware(s.Idempotency(http.HandlerFunc(s.HandlerCommentsCreate)))).Methods(http.MethodPost)
+	r.Handle("/comments/{id_comment:[0-9]+}", s.Middleware(http.HandlerFunc(s.HandlerComment))).Methods(http.MethodGet)
+	r.Handle("/comments/list/{id_task}", s.Middleware(http.HandlerFunc(s.HandlerComments))).Methods(http.MethodPost)
+	r.Handle("/comments/update/{id_comment}", s.Middleware(http.HandlerFunc(s.HandlerCommentsUpdate))).Methods(http.MethodPut)
+	r.Handle("/comments/delete/{id_comment}", s.Middleware(http.HandlerFunc(s.HandlerCommentsDelete))).Methods(http.MethodDelete)
+
+	r.Handle("/events", s.Middleware(http.HandlerFunc(s.HandlerEvents))).Methods(http.MethodGet)
+
+	r.Handle("/sync", s.Middleware(http.HandlerFunc(s.HandlerSyncPull))).Methods(http.MethodGet)
+	r.Handle("/sync", s.Middleware(http.HandlerFunc(s.HandlerSyncPush))).Methods(http.MethodPost)
+
+	r.Handle("/calendar/token", s.Middleware(http.HandlerFunc(s.HandlerCalendarToken))).Methods(http.MethodPost)
+	r.Handle("/calendar/{feed_token:[0-9a-f]+}.ics", http.HandlerFunc(s.HandlerCalendar)).Methods(http.MethodGet)
+
+	r.Handle("/graphql", s.Middleware(http.HandlerFunc(s.HandlerGraphQL))).Methods(http.MethodGet, http.MethodPost)
+
+	v2 := r.PathPrefix(server.APIV2Prefix).Subrouter()
+	v2.Handle("/tasks", s.Middleware(http.HandlerFunc(s.HandlerTasksQuery))).Methods(http.MethodGet)
+	v2.Handle("/tasks", s.Middleware(s.Idempotency(http.HandlerFunc(s.HandlerTasksCreate)))).Methods(http.MethodPost)
+	v2.Handle("/tasks/batch", s.Middleware(http.HandlerFunc(s.HandlerTasksBatch))).Methods(http.MethodPost)
+	v2.Handle("/tasks/{id_task:[0-9]+}", s.Middleware(http.HandlerFunc(s.HandlerTask))).Methods(http.MethodGet)
+	v2.Handle("/tasks/{id_task:[0-9]+}", s.Middleware(http.HandlerFunc(s.HandlerTasksPatch))).Methods(http.MethodPatch)
+	v2.Handle("/tasks/{id_task:[0-9]+}", s.Middleware(http.HandlerFunc(s.HandlerTaskDelete))).Methods(http.MethodDelete)
+	v2.Handle("/tasks/{id_task:[0-9]+}/comments", s.Middleware(http.HandlerFunc(s.HandlerTaskComments))).Methods(http.MethodGet)
+	v2.Handle("/tasks/{id_task:[0-9]+}/comments", s.Middleware(s.Idempotency(http.HandlerFunc(s.HandlerCommentsCreate)))).Methods(http.MethodPost)
+	v2.Handle("/comments/{id_comment:[0-9]+}", s.Middleware(http.HandlerFunc(s.HandlerComment))).Methods(http.MethodGet)
+
+	return r
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"testing"
+
+	"gitlab.com/vitbog/titov-rest/internal/metrics"
+	"gitlab.com/vitbog/titov-rest/internal/openapi"
+	"gitlab.com/vitbog/titov-rest/internal/server"
+)
+
+// TestRoutesMatchOpenAPI fails when a route is added without documenting it
+// in the OpenAPI document, or the other way round.
+func TestRoutesMatchOpenAPI(t *testing.T) {
+	s := &server.Server{Metrics: metrics.New()}
+
+	err := openapi.Verify(routes(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,13 +1,17 @@
 package db
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
-func (db *Db) Auth(login, password string) (string, error) {
+func (db *Db) Auth(ctx context.Context, login, password string) (string, error) {
+	ctx, span := spanStart(ctx, "Auth", "users.auth")
+	defer span.End()
+
 	schema := "users"
 	query := fmt.Sprintf("SELECT a.password, a.role from %s.auth($1) a", schema)
 	reply := []struct {
@@ -15,12 +19,12 @@ func (db *Db) Auth(login, password string) (string, error) {
 		RoleName string `json:"role" db:"role"`
 	}{}
 
-	err := db.Pg.Select(&reply, query, login)
+	err := db.Pg.SelectContext(ctx, &reply, query, login)
 	if err != nil {
 		return "", err
 	}
 	if len(reply) == 0 {
-		return "", fmt.Errorf("user not found")
+		return "", ErrInvalidCredentials
 	}
 
 	hashedPassword := reply[0].Password
@@ -30,11 +34,14 @@ func (db *Db) Auth(login, password string) (string, error) {
 	if err == nil {
 		return roleName, nil
 	} else {
-		return "", fmt.Errorf("wrong password")
+		return "", ErrInvalidCredentials
 	}
 }
 
-func (db *Db) Register(login, password, roleName, fname, lname string) (int64, error) {
+func (db *Db) Register(ctx context.Context, login, password, roleName, fname, lname string) (int64, error) {
+	ctx, span := spanStart(ctx, "Register", "users.register")
+	defer span.End()
+
 	schema := "users"
 	query := fmt.Sprintf("SELECT %s.register($1, $2, $3, $4, $5)", schema)
 	var userId int64
@@ -44,7 +51,7 @@ func (db *Db) Register(login, password, roleName, fname, lname string) (int64, e
 		return 0, errors.New("failed to hash password")
 	}
 
-	err = db.Pg.Get(&userId, query, login, hashedPassword, roleName, fname, lname)
+	err = db.Pg.GetContext(ctx, &userId, query, login, hashedPassword, roleName, fname, lname)
 	if err != nil {
 		return 0, err
 	}
@@ -0,0 +1,146 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"gitlab.com/vitbog/titov-rest/internal/filters"
+)
+
+const (
+	TaskBatchSetStatus   = "set_status"
+	TaskBatchAssign      = "assign"
+	TaskBatchRelabel     = "relabel"
+	TaskBatchMoveDueDate = "move_due_date"
+	TaskBatchDelete      = "delete"
+)
+
+var (
+	// ErrNoDueDate is a move_due_date shift of a task without a due date.
+	ErrNoDueDate = errors.New("task has no due date")
+	// ErrDueDatePast is a move_due_date shift to before NotBefore.
+	ErrDueDatePast = errors.New("due date is in the past")
+)
+
+// TaskBatchAction is one change applied to many tasks. Only the fields of
+// the action are used: Status for set_status, IdUser for assign, Title for
+// relabel, and DueDate or else Shift for move_due_date. A shift fails with
+// ErrNoDueDate for a task without a due date and, unless NotBefore is zero,
+// with ErrDueDatePast when it moves the due date before NotBefore.
+type TaskBatchAction struct {
+	Action    string
+	Status    string
+	IdUser    int64
+	Title     string
+	DueDate   time.Time
+	Shift     time.Duration
+	NotBefore time.Time
+}
+
+// TaskBatchResult has an error per task, ErrNotFound for a missing one, in
+// the order of Ids. Committed is false if any task failed, and then none of
+// them changed.
+type TaskBatchResult struct {
+	Ids       []int64
+	Errs      []error
+	Committed bool
+}
+
+// TasksBatch applies action to the tasks in ids or, when filt is not nil, to
+// the tasks matching filt, all in one transaction. More than maxItems tasks
+// is ErrBatchTooLarge.
+func (db *Db) TasksBatch(ctx context.Context, ids []int64, filt *filters.Filtering, action TaskBatchAction, maxItems int) (TaskBatchResult, error) {
+	ctx, span := spanStart(ctx, "TasksBatch", "tasks.tasks_batch_apply")
+	defer span.End()
+
+	schema := "tasks"
+	query := fmt.Sprintf("SELECT %s.tasks_batch_apply($1, $2, $3, $4, $5, $6, $7, $8)", schema)
+
+	tx, err := db.Pg.BeginTxx(ctx, nil)
+	if err != nil {
+		return TaskBatchResult{}, err
+	}
+	defer tx.Rollback()
+
+	if filt != nil {
+		filterQuery, args, err := db.tasksQuery(ctx, *filt)
+		if err != nil {
+			return TaskBatchResult{}, err
+		}
+		reply := []TaskDb{}
+		err = tx.SelectContext(ctx, &reply, filterQuery, args...)
+		if err != nil {
+			return TaskBatchResult{}, err
+		}
+		ids = make([]int64, 0, len(reply))
+		for _, t := range reply {
+			ids = append(ids, t.Id)
+		}
+	}
+	if len(ids) > maxItems {
+		return TaskBatchResult{}, fmt.Errorf("%w: %d tasks, at most %d allowed", ErrBatchTooLarge, len(ids), maxItems)
+	}
+
+	dueDate := sql.NullTime{Time: action.DueDate, Valid: !action.DueDate.IsZero()}
+	notBefore := sql.NullTime{Time: action.NotBefore.UTC(), Valid: !action.NotBefore.IsZero()}
+	result := TaskBatchResult{Ids: ids, Errs: make([]error, len(ids)), Committed: true}
+	for i, id := range ids {
+		// A failed statement aborts the whole transaction, so every task
+		// gets a savepoint to roll back to and the rest can still report.
+		_, err = tx.ExecContext(ctx, "SAVEPOINT batch_item")
+		if err != nil {
+			return TaskBatchResult{}, err
+		}
+
+		var taskId sql.NullInt64
+		err = tx.GetContext(ctx, &taskId, query, id, action.Action, action.Status, action.IdUser, action.Title, dueDate, int64(action.Shift.Seconds()), notBefore)
+		if err == nil && !taskId.Valid {
+			err = ErrNotFound
+		}
+		err = taskBatchError(err)
+
+		if err != nil {
+			result.Errs[i] = err
+			result.Committed = false
+			_, err = tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT batch_item")
+		} else {
+			_, err = tx.ExecContext(ctx, "RELEASE SAVEPOINT batch_item")
+		}
+		if err != nil {
+			return TaskBatchResult{}, err
+		}
+	}
+
+	if !result.Committed {
+		return result, nil
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return TaskBatchResult{}, err
+	}
+
+	return result, nil
+}
+
+// taskBatchError maps the exceptions tasks_batch_apply raises for a shift it
+// refuses to ErrNoDueDate and ErrDueDatePast.
+func taskBatchError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+
+	switch {
+	case strings.HasPrefix(pqErr.Message, "no due date to shift"):
+		return fmt.Errorf("%w: %w", ErrNoDueDate, err)
+	case strings.HasPrefix(pqErr.Message, "due date in the past"):
+		return fmt.Errorf("%w: %w", ErrDueDatePast, err)
+	}
+	return err
+}
@@ -0,0 +1,68 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// FeedTokenGenerate creates a new random calendar feed token.
+func FeedTokenGenerate() (string, error) {
+	raw := make([]byte, 24)
+	_, err := rand.Read(raw)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+// FeedTokenHash is what is stored of a feed token. Only a hash is stored,
+// like passwords are, so the database alone is not enough to read anyone's
+// calendar.
+func FeedTokenHash(feedToken string) string {
+	sum := sha256.Sum256([]byte(feedToken))
+	return hex.EncodeToString(sum[:])
+}
+
+// FeedTokenSet replaces the calendar feed token of the user, which revokes
+// the previous feed URL.
+func (db *Db) FeedTokenSet(ctx context.Context, login, feedToken string) error {
+	ctx, span := spanStart(ctx, "FeedTokenSet", "users.feed_token_set")
+	defer span.End()
+
+	schema := "users"
+	query := fmt.Sprintf("SELECT %s.feed_token_set($1, $2)", schema)
+	var userId int64
+
+	err := db.Pg.GetContext(ctx, &userId, query, login, FeedTokenHash(feedToken))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// FeedTokenUser returns the id and login of the user owning the feed token.
+func (db *Db) FeedTokenUser(ctx context.Context, feedToken string) (int64, string, error) {
+	ctx, span := spanStart(ctx, "FeedTokenUser", "users.feed_token_user")
+	defer span.End()
+
+	schema := "users"
+	query := fmt.Sprintf("SELECT u.id, u.login from %s.feed_token_user($1) u", schema)
+	reply := []struct {
+		Id    int64  `db:"id"`
+		Login string `db:"login"`
+	}{}
+
+	err := db.Pg.SelectContext(ctx, &reply, query, FeedTokenHash(feedToken))
+	if err != nil {
+		return 0, "", err
+	}
+	if len(reply) == 0 {
+		return 0, "", ErrNotFound
+	}
+
+	return reply[0].Id, reply[0].Login, nil
+}
@@ -1,10 +1,12 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"time"
 
+	"github.com/lib/pq"
 	"gitlab.com/vitbog/titov-rest/internal/filters"
 )
 
@@ -14,6 +16,7 @@ type CommentModel struct {
 	IdTask    int64     `json:"id_task" db:"id_task"`
 	Content   string    `json:"content" db:"content"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
 }
 
 type CommentDb struct {
@@ -22,6 +25,7 @@ type CommentDb struct {
 	IdTask    int64          `json:"id_task" db:"id_task"`
 	Content   sql.NullString `json:"content" db:"content"`
 	CreatedAt sql.NullTime   `json:"created_at" db:"created_at"`
+	UpdatedAt sql.NullTime   `json:"updated_at" db:"updated_at"`
 }
 
 func (db *Db) CommentConvertFromDb(t CommentDb) (CommentModel, error) {
@@ -32,6 +36,7 @@ func (db *Db) CommentConvertFromDb(t CommentDb) (CommentModel, error) {
 		IdTask:    t.IdTask,
 		Content:   t.Content.String,
 		CreatedAt: t.CreatedAt.Time,
+		UpdatedAt: t.UpdatedAt.Time,
 	}, nil
 }
 
@@ -50,17 +55,41 @@ func (db *Db) CommentsConvertFromDb(comments []CommentDb) ([]CommentModel, error
 }
 
 var (
-	glCommentsAllowedColumns = []string{"id", "id_user", "id_task", "content", "created_at"}
+	CommentsAllowedColumns = []string{"id", "id_user", "id_task", "content", "created_at", "updated_at"}
 )
 
-func (db *Db) Comments(taskId int64, filt filters.Filtering) ([]CommentModel, error) {
+// CommentColumn is TaskColumn for comments.
+func CommentColumn(c CommentModel, name string) interface{} {
+	switch name {
+	case "id":
+		return c.Id
+	case "id_user":
+		return c.IdUser
+	case "id_task":
+		return c.IdTask
+	case "content":
+		return c.Content
+	case "created_at":
+		return c.CreatedAt
+	case "updated_at":
+		return c.UpdatedAt
+	}
+	return nil
+}
+
+func (db *Db) Comments(ctx context.Context, taskId int64, filt filters.Filtering) ([]CommentModel, error) {
+	ctx, span := spanStart(ctx, "Comments", "tasks.comments_list")
+	defer span.End()
+
 	schema := "tasks"
-	query := fmt.Sprintf("SELECT c.id, c.id_user, c.id_task, c.content, c.created_at from %s.comments_list($1) c", schema)
+	query := fmt.Sprintf("SELECT c.id, c.id_user, c.id_task, c.content, c.created_at, c.updated_at from %s.comments_list($1) c", schema)
 	narg := 1
 
-	filterQuery, filterArgs, err := filt.Filter(query, 1, glCommentsAllowedColumns, "id", "id_user", "id_task", "content", "created_at")
+	_, filterSpan := filterSpanStart(ctx)
+	filterQuery, filterArgs, err := filt.Filter(query, 1, CommentsAllowedColumns, "id", "id_user", "id_task", "content", "created_at", "updated_at")
+	filterSpan.End()
 	if err != nil {
-		return nil, fmt.Errorf("error filtering: %v", err)
+		return nil, fmt.Errorf("%w: %v", ErrFiltering, err)
 	}
 
 	args := make([]interface{}, narg, len(filterArgs)+narg)
@@ -68,7 +97,7 @@ func (db *Db) Comments(taskId int64, filt filters.Filtering) ([]CommentModel, er
 	args = append(args, filterArgs...)
 
 	reply := []CommentDb{}
-	err = db.Pg.Select(&reply, filterQuery, args...)
+	err = db.Pg.SelectContext(ctx, &reply, filterQuery, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -81,15 +110,66 @@ func (db *Db) Comments(taskId int64, filt filters.Filtering) ([]CommentModel, er
 	return converted, nil
 }
 
-func (db *Db) CommentCreate(taskId int64, userLogin, content string) (int64, error) {
+// Comment returns the comment with the given id or ErrNotFound.
+func (db *Db) Comment(ctx context.Context, commentId int64) (CommentModel, error) {
+	ctx, span := spanStart(ctx, "Comment", "tasks.comments_get")
+	defer span.End()
+
+	schema := "tasks"
+	query := fmt.Sprintf("SELECT c.id, c.id_user, c.id_task, c.content, c.created_at, c.updated_at from %s.comments_get($1) c", schema)
+
+	reply := []CommentDb{}
+	err := db.Pg.SelectContext(ctx, &reply, query, commentId)
+	if err != nil {
+		return CommentModel{}, err
+	}
+	if len(reply) == 0 {
+		return CommentModel{}, ErrNotFound
+	}
+
+	return db.CommentConvertFromDb(reply[0])
+}
+
+func (db *Db) CommentCreate(ctx context.Context, taskId int64, userLogin, content string) (int64, error) {
+	ctx, span := spanStart(ctx, "CommentCreate", "tasks.comment_create")
+	defer span.End()
+
 	schema := "tasks"
 	query := fmt.Sprintf("SELECT %s.comment_create($1, $2, $3)", schema)
 	var commentId int64
 
-	err := db.Pg.Get(&commentId, query, taskId, userLogin, content)
+	err := db.Pg.GetContext(ctx, &commentId, query, taskId, userLogin, content)
 	if err != nil {
 		return 0, err
 	}
 
 	return commentId, nil
 }
+
+// CommentsByTasks returns the comments of all given tasks in one query,
+// grouped by task id.
+func (db *Db) CommentsByTasks(ctx context.Context, taskIds []int64) (map[int64][]CommentModel, error) {
+	ctx, span := spanStart(ctx, "CommentsByTasks", "tasks.comments_list_by_tasks")
+	defer span.End()
+
+	schema := "tasks"
+	query := fmt.Sprintf("SELECT c.id, c.id_user, c.id_task, c.content, c.created_at, c.updated_at from %s.comments_list_by_tasks($1) c", schema)
+
+	reply := []CommentDb{}
+	err := db.Pg.SelectContext(ctx, &reply, query, pq.Array(taskIds))
+	if err != nil {
+		return nil, err
+	}
+
+	converted, err := db.CommentsConvertFromDb(reply)
+	if err != nil {
+		return nil, err
+	}
+
+	grouped := make(map[int64][]CommentModel, len(taskIds))
+	for _, c := range converted {
+		grouped[c.IdTask] = append(grouped[c.IdTask], c)
+	}
+
+	return grouped, nil
+}
@@ -1,6 +1,9 @@
 package db
 
 import (
+	"context"
+	"time"
+
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
 )
@@ -9,10 +12,33 @@ type Db struct {
 	Pg sqlx.DB
 }
 
-func New(connStr string) (*Db, error) {
+// Pool sizes the connection pool, as the sql.DB setters of the same names
+// take it; idle connections beyond MaxOpenConns are not kept.
+type Pool struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+func New(connStr string, pool Pool) (*Db, error) {
 	db, err := sqlx.Connect("postgres", connStr)
 	if err != nil {
 		return nil, err
 	}
+
+	db.SetMaxOpenConns(pool.MaxOpenConns)
+	db.SetMaxIdleConns(pool.MaxIdleConns)
+	db.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+
 	return &Db{Pg: *db}, nil
 }
+
+func (d *Db) Ping(ctx context.Context) error {
+	return d.Pg.PingContext(ctx)
+}
+
+func (d *Db) Close() error {
+	return d.Pg.Close()
+}
@@ -0,0 +1,36 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNotFound           = errors.New("not found")
+	ErrInvalidCredentials = errors.New("invalid login or password")
+	ErrFiltering          = errors.New("error filtering")
+	ErrBatchTooLarge      = errors.New("batch is too large")
+
+	// Errors of storages other than Postgres, whose errors are *pq.Error
+	// instead; see ConstraintError.
+	ErrInvalidValue     = errors.New("invalid value")
+	ErrAlreadyExists    = errors.New("already exists")
+	ErrStillReferenced  = errors.New("still referenced")
+	ErrMissingReference = errors.New("refers to a missing row")
+)
+
+// ConstraintError is a violated constraint on Field: Err is ErrAlreadyExists
+// for a unique one, ErrStillReferenced or ErrMissingReference for a foreign
+// key.
+type ConstraintError struct {
+	Err   error
+	Field string
+}
+
+func (e *ConstraintError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Field, e.Err)
+}
+
+func (e *ConstraintError) Unwrap() error {
+	return e.Err
+}
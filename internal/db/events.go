@@ -0,0 +1,126 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	EventEntityTask    = "task"
+	EventEntityComment = "comment"
+)
+
+const (
+	EventActionInsert = "insert"
+	EventActionUpdate = "update"
+	EventActionDelete = "delete"
+)
+
+type EventModel struct {
+	Id        int64           `json:"id" db:"id"`
+	Entity    string          `json:"entity" db:"entity"`
+	Action    string          `json:"action" db:"action"`
+	IdEntity  int64           `json:"id_entity" db:"id_entity"`
+	Payload   json.RawMessage `json:"payload" db:"payload"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+}
+
+type EventDb struct {
+	Id        int64          `json:"id" db:"id"`
+	Entity    string         `json:"entity" db:"entity"`
+	Action    string         `json:"action" db:"action"`
+	IdEntity  int64          `json:"id_entity" db:"id_entity"`
+	Payload   sql.NullString `json:"payload" db:"payload"`
+	CreatedAt sql.NullTime   `json:"created_at" db:"created_at"`
+}
+
+func (db *Db) EventConvertFromDb(e EventDb) (EventModel, error) {
+	payload := json.RawMessage("null")
+	if e.Payload.Valid {
+		payload = json.RawMessage(e.Payload.String)
+	}
+
+	return EventModel{
+		Id:        e.Id,
+		Entity:    e.Entity,
+		Action:    e.Action,
+		IdEntity:  e.IdEntity,
+		Payload:   payload,
+		CreatedAt: e.CreatedAt.Time,
+	}, nil
+}
+
+func (db *Db) EventsConvertFromDb(events []EventDb) ([]EventModel, error) {
+	convertedEvents := make([]EventModel, 0, len(events))
+	for _, e := range events {
+		convertedEvent, err := db.EventConvertFromDb(e)
+		if err != nil {
+			return nil, err
+		}
+
+		convertedEvents = append(convertedEvents, convertedEvent)
+	}
+
+	return convertedEvents, nil
+}
+
+// Events returns at most limit events with id greater than afterId, oldest first.
+// Events are held back while a transaction that could still commit a lower id
+// is running, so a reader that passes the last id it saw as afterId never
+// skips an event committed later. A long writing transaction delays the events
+// after it until it ends.
+func (db *Db) Events(ctx context.Context, afterId int64, limit int) ([]EventModel, error) {
+	ctx, span := spanStart(ctx, "Events", "tasks.events_list")
+	defer span.End()
+
+	schema := "tasks"
+	query := fmt.Sprintf("SELECT e.id, e.entity, e.action, e.id_entity, e.payload, e.created_at from %s.events_list($1, $2) e", schema)
+
+	reply := []EventDb{}
+	err := db.Pg.SelectContext(ctx, &reply, query, afterId, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	converted, err := db.EventsConvertFromDb(reply)
+	if err != nil {
+		return nil, err
+	}
+
+	return converted, nil
+}
+
+// EventsLastId is the id of the last event Events would return.
+func (db *Db) EventsLastId(ctx context.Context) (int64, error) {
+	ctx, span := spanStart(ctx, "EventsLastId", "tasks.events_last_id")
+	defer span.End()
+
+	schema := "tasks"
+	query := fmt.Sprintf("SELECT %s.events_last_id()", schema)
+	var eventId int64
+
+	err := db.Pg.GetContext(ctx, &eventId, query)
+	if err != nil {
+		return 0, err
+	}
+
+	return eventId, nil
+}
+
+func (db *Db) EventsCleanup(ctx context.Context, before time.Time) error {
+	ctx, span := spanStart(ctx, "EventsCleanup", "tasks.events_cleanup")
+	defer span.End()
+
+	schema := "tasks"
+	query := fmt.Sprintf("CALL %s.events_cleanup($1)", schema)
+
+	_, err := db.Pg.ExecContext(ctx, query, before)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// IdempotencyModel is what is stored for an Idempotency-Key. Status is 0
+// while the first request with the key is still running.
+type IdempotencyModel struct {
+	Created     bool
+	RequestHash string
+	Status      int
+	Headers     map[string]string
+	Body        []byte
+	CreatedAt   time.Time
+}
+
+type IdempotencyDb struct {
+	Created     bool           `db:"created"`
+	RequestHash string         `db:"request_hash"`
+	Status      sql.NullInt64  `db:"status"`
+	Headers     sql.NullString `db:"headers"`
+	Body        []byte         `db:"body"`
+	CreatedAt   sql.NullTime   `db:"created_at"`
+}
+
+func (db *Db) IdempotencyConvertFromDb(i IdempotencyDb) (IdempotencyModel, error) {
+	headers := map[string]string{}
+	if i.Headers.Valid {
+		err := json.Unmarshal([]byte(i.Headers.String), &headers)
+		if err != nil {
+			return IdempotencyModel{}, err
+		}
+	}
+
+	return IdempotencyModel{
+		Created:     i.Created,
+		RequestHash: i.RequestHash,
+		Status:      int(i.Status.Int64),
+		Headers:     headers,
+		Body:        i.Body,
+		CreatedAt:   i.CreatedAt.Time,
+	}, nil
+}
+
+// IdempotencyBegin claims key within scope for a request with requestHash.
+// Created is true when the caller owns the key and must finish or release it;
+// otherwise the stored request is returned. Keys older than window are
+// treated as unused.
+func (db *Db) IdempotencyBegin(ctx context.Context, scope, key, requestHash string, window time.Duration) (IdempotencyModel, error) {
+	ctx, span := spanStart(ctx, "IdempotencyBegin", "tasks.idempotency_begin")
+	defer span.End()
+
+	schema := "tasks"
+	query := fmt.Sprintf("SELECT i.created, i.request_hash, i.status, i.headers, i.body, i.created_at from %s.idempotency_begin($1, $2, $3, $4) i", schema)
+
+	reply := IdempotencyDb{}
+	err := db.Pg.GetContext(ctx, &reply, query, scope, key, requestHash, int64(window.Seconds()))
+	if err != nil {
+		return IdempotencyModel{}, err
+	}
+
+	return db.IdempotencyConvertFromDb(reply)
+}
+
+// IdempotencyFinish stores the response to replay for later requests with key.
+func (db *Db) IdempotencyFinish(ctx context.Context, scope, key string, status int, headers map[string]string, body []byte) error {
+	ctx, span := spanStart(ctx, "IdempotencyFinish", "tasks.idempotency_finish")
+	defer span.End()
+
+	schema := "tasks"
+	query := fmt.Sprintf("CALL %s.idempotency_finish($1, $2, $3, $4, $5)", schema)
+
+	headersJSON, err := json.Marshal(headers)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Pg.ExecContext(ctx, query, scope, key, status, string(headersJSON), body)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// IdempotencyRelease frees an unfinished key so the request can be retried.
+func (db *Db) IdempotencyRelease(ctx context.Context, scope, key string) error {
+	ctx, span := spanStart(ctx, "IdempotencyRelease", "tasks.idempotency_release")
+	defer span.End()
+
+	schema := "tasks"
+	query := fmt.Sprintf("CALL %s.idempotency_release($1, $2)", schema)
+
+	_, err := db.Pg.ExecContext(ctx, query, scope, key)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (db *Db) IdempotencyCleanup(ctx context.Context, before time.Time) error {
+	ctx, span := spanStart(ctx, "IdempotencyCleanup", "tasks.idempotency_cleanup")
+	defer span.End()
+
+	schema := "tasks"
+	query := fmt.Sprintf("CALL %s.idempotency_cleanup($1)", schema)
+
+	_, err := db.Pg.ExecContext(ctx, query, before)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
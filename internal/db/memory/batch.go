@@ -0,0 +1,101 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"maps"
+
+	"gitlab.com/vitbog/titov-rest/internal/db"
+	"gitlab.com/vitbog/titov-rest/internal/filters"
+)
+
+func (s *Store) TasksBatch(ctx context.Context, ids []int64, filt *filters.Filtering, action db.TaskBatchAction, maxItems int) (db.TaskBatchResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if filt != nil {
+		tasks, err := s.tasksFilter(*filt)
+		if err != nil {
+			return db.TaskBatchResult{}, err
+		}
+		ids = make([]int64, 0, len(tasks))
+		for _, t := range tasks {
+			ids = append(ids, t.Id)
+		}
+	}
+
+	if len(ids) > maxItems {
+		return db.TaskBatchResult{}, fmt.Errorf("%w: %d tasks, at most %d allowed", db.ErrBatchTooLarge, len(ids), maxItems)
+	}
+
+	// The transaction: everything is put back unless all tasks succeed.
+	tasks, events, tombstones, lastEventId := maps.Clone(s.tasks), len(s.events), len(s.tombstones), s.lastEventId
+
+	result := db.TaskBatchResult{Ids: ids, Errs: make([]error, len(ids)), Committed: true}
+	for i, id := range ids {
+		err := s.taskBatchApply(id, action)
+		if err != nil {
+			result.Errs[i] = err
+			result.Committed = false
+		}
+	}
+
+	if !result.Committed {
+		s.tasks, s.events, s.tombstones, s.lastEventId = tasks, s.events[:events], s.tombstones[:tombstones], lastEventId
+	}
+	return result, nil
+}
+
+// taskBatchApply is tasks.tasks_batch_apply: it changes nothing unless it
+// succeeds. Must be called with mu held.
+func (s *Store) taskBatchApply(id int64, action db.TaskBatchAction) error {
+	t, ok := s.tasks[id]
+
+	switch action.Action {
+	case db.TaskBatchSetStatus:
+		err := taskStatusCheck(action.Status)
+		if err != nil {
+			return err
+		}
+		t.Status = db.TaskStatus(action.Status)
+	case db.TaskBatchAssign:
+		// Like the foreign key, only a row actually updated is checked.
+		if _, exists := s.users[action.IdUser]; ok && !exists {
+			return &db.ConstraintError{Err: db.ErrMissingReference, Field: "id_user"}
+		}
+		t.IdUser = action.IdUser
+	case db.TaskBatchRelabel:
+		t.Title = action.Title
+	case db.TaskBatchMoveDueDate:
+		switch {
+		case !action.DueDate.IsZero():
+			t.DueDate = action.DueDate
+		case !ok:
+			// Reported as not found below.
+		case t.DueDate.IsZero():
+			return db.ErrNoDueDate
+		case t.DueDate.Add(action.Shift).Before(action.NotBefore):
+			return db.ErrDueDatePast
+		default:
+			t.DueDate = t.DueDate.Add(action.Shift)
+		}
+	case db.TaskBatchDelete:
+		if !ok {
+			return db.ErrNotFound
+		}
+		err := s.taskDeleteCheck(id)
+		if err != nil {
+			return err
+		}
+		s.taskDelete(id)
+		return nil
+	default:
+		return fmt.Errorf("unknown batch action %s", action.Action)
+	}
+
+	if !ok {
+		return db.ErrNotFound
+	}
+	s.taskUpdate(t)
+	return nil
+}
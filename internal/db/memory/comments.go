@@ -0,0 +1,97 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+
+	"gitlab.com/vitbog/titov-rest/internal/db"
+	"gitlab.com/vitbog/titov-rest/internal/filters"
+)
+
+// commentsSorted returns the comments matching keep in id order. Must be
+// called with mu held.
+func (s *Store) commentsSorted(keep func(db.CommentModel) bool) []db.CommentModel {
+	comments := make([]db.CommentModel, 0)
+	for _, c := range s.comments {
+		if keep(c) {
+			comments = append(comments, c)
+		}
+	}
+	slices.SortFunc(comments, func(a, b db.CommentModel) int {
+		return cmp.Compare(a.Id, b.Id)
+	})
+	return comments
+}
+
+func (s *Store) Comments(ctx context.Context, taskId int64, filt filters.Filtering) ([]db.CommentModel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	comments := s.commentsSorted(func(c db.CommentModel) bool { return c.IdTask == taskId })
+	comments, err := filters.Apply(filt, comments, db.CommentsAllowedColumns, db.CommentsAllowedColumns, db.CommentColumn)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", db.ErrFiltering, err)
+	}
+	return comments, nil
+}
+
+func (s *Store) Comment(ctx context.Context, commentId int64) (db.CommentModel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.comments[commentId]
+	if !ok {
+		return db.CommentModel{}, db.ErrNotFound
+	}
+	return c, nil
+}
+
+func (s *Store) CommentCreate(ctx context.Context, taskId int64, userLogin, content string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, err := s.userByLogin(userLogin)
+	if err != nil {
+		return 0, err
+	}
+	if _, ok := s.tasks[taskId]; !ok {
+		return 0, &db.ConstraintError{Err: db.ErrMissingReference, Field: "id_task"}
+	}
+
+	now := s.now()
+	s.lastCommentId++
+	c := db.CommentModel{
+		Id:        s.lastCommentId,
+		IdUser:    u.Id,
+		IdTask:    taskId,
+		Content:   content,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	s.comments[c.Id] = c
+	s.record(db.EventEntityComment, db.EventActionInsert, c.Id, c)
+
+	return c.Id, nil
+}
+
+func (s *Store) CommentsByTasks(ctx context.Context, taskIds []int64) (map[int64][]db.CommentModel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wanted := make(map[int64]bool, len(taskIds))
+	for _, id := range taskIds {
+		wanted[id] = true
+	}
+	comments := s.commentsSorted(func(c db.CommentModel) bool { return wanted[c.IdTask] })
+	slices.SortStableFunc(comments, func(a, b db.CommentModel) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	grouped := make(map[int64][]db.CommentModel, len(taskIds))
+	for _, c := range comments {
+		grouped[c.IdTask] = append(grouped[c.IdTask], c)
+	}
+	return grouped, nil
+}
@@ -0,0 +1,48 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"gitlab.com/vitbog/titov-rest/internal/db"
+)
+
+func (s *Store) Events(ctx context.Context, afterId int64, limit int) ([]db.EventModel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events := make([]db.EventModel, 0)
+	for _, e := range s.events {
+		if len(events) == limit {
+			break
+		}
+		if e.Id > afterId {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+func (s *Store) EventsLastId(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.events) == 0 {
+		return 0, nil
+	}
+	return s.events[len(s.events)-1].Id, nil
+}
+
+func (s *Store) EventsCleanup(ctx context.Context, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.events[:0]
+	for _, e := range s.events {
+		if !e.CreatedAt.Before(before) {
+			kept = append(kept, e)
+		}
+	}
+	s.events = kept
+	return nil
+}
@@ -0,0 +1,71 @@
+package memory
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"time"
+
+	"gitlab.com/vitbog/titov-rest/internal/db"
+)
+
+type idempotencyKey struct {
+	scope string
+	key   string
+}
+
+func (s *Store) IdempotencyBegin(ctx context.Context, scope, key, requestHash string, window time.Duration) (db.IdempotencyModel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	k := idempotencyKey{scope: scope, key: key}
+	stored, ok := s.idempotency[k]
+	if ok && stored.CreatedAt.Before(now.Add(-window.Truncate(time.Second))) {
+		ok = false
+	}
+	if ok {
+		stored.Created = false
+		stored.Headers = maps.Clone(stored.Headers)
+		stored.Body = slices.Clone(stored.Body)
+		return stored, nil
+	}
+
+	s.idempotency[k] = db.IdempotencyModel{RequestHash: requestHash, Headers: map[string]string{}, CreatedAt: now}
+	return db.IdempotencyModel{Created: true, RequestHash: requestHash, Headers: map[string]string{}, CreatedAt: now}, nil
+}
+
+func (s *Store) IdempotencyFinish(ctx context.Context, scope, key string, status int, headers map[string]string, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := idempotencyKey{scope: scope, key: key}
+	stored, ok := s.idempotency[k]
+	if !ok {
+		return nil
+	}
+	stored.Status, stored.Headers, stored.Body = status, maps.Clone(headers), slices.Clone(body)
+	s.idempotency[k] = stored
+	return nil
+}
+
+func (s *Store) IdempotencyRelease(ctx context.Context, scope, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := idempotencyKey{scope: scope, key: key}
+	if stored, ok := s.idempotency[k]; ok && stored.Status == 0 {
+		delete(s.idempotency, k)
+	}
+	return nil
+}
+
+func (s *Store) IdempotencyCleanup(ctx context.Context, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	maps.DeleteFunc(s.idempotency, func(_ idempotencyKey, stored db.IdempotencyModel) bool {
+		return stored.CreatedAt.Before(before)
+	})
+	return nil
+}
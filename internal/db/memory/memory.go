@@ -0,0 +1,79 @@
+// Package memory is a db.Storage kept in memory, for tests and demo mode. It
+// mirrors the Postgres schema and functions: the same ids, timestamps,
+// constraints, change events and tombstones, and filtering through
+// filters.Apply. Everything is lost when the process exits.
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"gitlab.com/vitbog/titov-rest/internal/db"
+)
+
+type Store struct {
+	mu sync.Mutex
+
+	users    map[int64]*user
+	tasks    map[int64]db.TaskModel
+	comments map[int64]db.CommentModel
+
+	events      []db.EventModel
+	tombstones  []db.TombstoneModel
+	idempotency map[idempotencyKey]db.IdempotencyModel
+
+	lastUserId    int64
+	lastTaskId    int64
+	lastCommentId int64
+	lastEventId   int64
+
+	// Now is the clock used for every timestamp, like NOW() in the Postgres
+	// functions.
+	Now func() time.Time
+}
+
+var _ db.Storage = (*Store)(nil)
+
+func New() *Store {
+	return &Store{
+		users:       make(map[int64]*user),
+		tasks:       make(map[int64]db.TaskModel),
+		comments:    make(map[int64]db.CommentModel),
+		idempotency: make(map[idempotencyKey]db.IdempotencyModel),
+		Now:         time.Now,
+	}
+}
+
+func (s *Store) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (s *Store) Close() error {
+	return nil
+}
+
+// now is Now with the precision and zone of a Postgres timestamp without
+// time zone.
+func (s *Store) now() time.Time {
+	return s.Now().UTC().Truncate(time.Microsecond)
+}
+
+// record adds the event the tasks.events_notify trigger would for row.
+func (s *Store) record(entity, action string, idEntity int64, row interface{}) {
+	payload, err := json.Marshal(row)
+	if err != nil {
+		payload = json.RawMessage("null")
+	}
+
+	s.lastEventId++
+	s.events = append(s.events, db.EventModel{
+		Id:        s.lastEventId,
+		Entity:    entity,
+		Action:    action,
+		IdEntity:  idEntity,
+		Payload:   payload,
+		CreatedAt: s.now(),
+	})
+}
@@ -0,0 +1,116 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"gitlab.com/vitbog/titov-rest/internal/db"
+)
+
+func (s *Store) SyncNow(ctx context.Context) (time.Time, error) {
+	return s.now(), nil
+}
+
+func inSyncWindow(t, since, until time.Time) bool {
+	return t.After(since) && !t.After(until)
+}
+
+func (s *Store) SyncTasks(ctx context.Context, since, until time.Time) ([]db.TaskModel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tasks := make([]db.TaskModel, 0)
+	for _, t := range s.tasksSorted() {
+		if inSyncWindow(t.UpdatedAt, since, until) {
+			tasks = append(tasks, t)
+		}
+	}
+	slices.SortStableFunc(tasks, func(a, b db.TaskModel) int {
+		return a.UpdatedAt.Compare(b.UpdatedAt)
+	})
+	return tasks, nil
+}
+
+func (s *Store) SyncComments(ctx context.Context, since, until time.Time) ([]db.CommentModel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	comments := s.commentsSorted(func(c db.CommentModel) bool { return inSyncWindow(c.UpdatedAt, since, until) })
+	slices.SortStableFunc(comments, func(a, b db.CommentModel) int {
+		return a.UpdatedAt.Compare(b.UpdatedAt)
+	})
+	return comments, nil
+}
+
+func (s *Store) SyncTombstones(ctx context.Context, since, until time.Time) ([]db.TombstoneModel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tombstones := make([]db.TombstoneModel, 0)
+	for _, t := range s.tombstones {
+		if inSyncWindow(t.DeletedAt, since, until) {
+			tombstones = append(tombstones, t)
+		}
+	}
+	slices.SortStableFunc(tombstones, func(a, b db.TombstoneModel) int {
+		return a.DeletedAt.Compare(b.DeletedAt)
+	})
+	return tombstones, nil
+}
+
+func (s *Store) SyncTombstonesCleanup(ctx context.Context, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tombstones = slices.DeleteFunc(s.tombstones, func(t db.TombstoneModel) bool {
+		return t.DeletedAt.Before(before)
+	})
+	return nil
+}
+
+// syncStatus reports whether the task may be changed by a client that last
+// saw it at baseUpdatedAt. Must be called with mu held.
+func (s *Store) syncStatus(taskId int64, baseUpdatedAt *time.Time) string {
+	t, ok := s.tasks[taskId]
+	switch {
+	case !ok:
+		return db.SyncStatusNotFound
+	case baseUpdatedAt != nil && t.UpdatedAt.After(*baseUpdatedAt):
+		return db.SyncStatusConflict
+	}
+	return db.SyncStatusApplied
+}
+
+func (s *Store) SyncTaskUpdate(ctx context.Context, taskId int64, taskTitle, taskDescription, taskStatus string, DueDate time.Time, baseUpdatedAt *time.Time) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := taskStatusCheck(taskStatus)
+	if err != nil {
+		return "", err
+	}
+
+	status := s.syncStatus(taskId, baseUpdatedAt)
+	if status == db.SyncStatusApplied {
+		t := s.tasks[taskId]
+		t.Title, t.Description, t.Status, t.DueDate = taskTitle, taskDescription, db.TaskStatus(taskStatus), DueDate
+		s.taskUpdate(t)
+	}
+	return status, nil
+}
+
+func (s *Store) SyncTaskDelete(ctx context.Context, taskId int64, baseUpdatedAt *time.Time) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := s.syncStatus(taskId, baseUpdatedAt)
+	if status == db.SyncStatusApplied {
+		err := s.taskDeleteCheck(taskId)
+		if err != nil {
+			return "", err
+		}
+		s.taskDelete(taskId)
+	}
+	return status, nil
+}
@@ -0,0 +1,235 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"gitlab.com/vitbog/titov-rest/internal/db"
+	"gitlab.com/vitbog/titov-rest/internal/filters"
+)
+
+// taskStatusCheck is the cast to the task_status enum.
+func taskStatusCheck(taskStatus string) error {
+	if !db.TaskStatusIsValid(taskStatus) {
+		return fmt.Errorf("%w: task status %q", db.ErrInvalidValue, taskStatus)
+	}
+	return nil
+}
+
+// tasksSorted returns the tasks in id order, which is the order Postgres
+// returns them in without a sort. Must be called with mu held.
+func (s *Store) tasksSorted() []db.TaskModel {
+	tasks := make([]db.TaskModel, 0, len(s.tasks))
+	for _, t := range s.tasks {
+		tasks = append(tasks, t)
+	}
+	slices.SortFunc(tasks, func(a, b db.TaskModel) int {
+		return cmp.Compare(a.Id, b.Id)
+	})
+	return tasks
+}
+
+// tasksFilter must be called with mu held.
+func (s *Store) tasksFilter(filt filters.Filtering) ([]db.TaskModel, error) {
+	tasks, err := filters.Apply(filt, s.tasksSorted(), db.TasksAllowedColumns, db.TasksAllowedColumns, db.TaskColumn)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", db.ErrFiltering, err)
+	}
+	return tasks, nil
+}
+
+// taskCreate must be called with mu held.
+func (s *Store) taskCreate(userLogin, taskTitle, taskDescription, taskStatus string, DueDate time.Time) (int64, error) {
+	u, err := s.userByLogin(userLogin)
+	if err != nil {
+		return 0, err
+	}
+	err = taskStatusCheck(taskStatus)
+	if err != nil {
+		return 0, err
+	}
+
+	now := s.now()
+	s.lastTaskId++
+	t := db.TaskModel{
+		Id:          s.lastTaskId,
+		IdUser:      u.Id,
+		Title:       taskTitle,
+		Description: taskDescription,
+		Status:      db.TaskStatus(taskStatus),
+		CreatedAt:   now,
+		DueDate:     DueDate,
+		UpdatedAt:   now,
+	}
+	s.tasks[t.Id] = t
+	s.record(db.EventEntityTask, db.EventActionInsert, t.Id, db.TaskRowConvert(t))
+
+	return t.Id, nil
+}
+
+// taskUpdate stores t as changed and records it. Must be called with mu
+// held.
+func (s *Store) taskUpdate(t db.TaskModel) {
+	t.UpdatedAt = s.now()
+	s.tasks[t.Id] = t
+	s.record(db.EventEntityTask, db.EventActionUpdate, t.Id, db.TaskRowConvert(t))
+}
+
+// taskDeleteCheck is the foreign key from comments. Must be called with mu
+// held.
+func (s *Store) taskDeleteCheck(taskId int64) error {
+	for _, c := range s.comments {
+		if c.IdTask == taskId {
+			return &db.ConstraintError{Err: db.ErrStillReferenced, Field: "id_task"}
+		}
+	}
+	return nil
+}
+
+// taskDelete must be called with mu held, after taskDeleteCheck.
+func (s *Store) taskDelete(taskId int64) {
+	t := s.tasks[taskId]
+	delete(s.tasks, taskId)
+	s.tombstones = append(s.tombstones, db.TombstoneModel{Entity: db.EventEntityTask, IdEntity: taskId, DeletedAt: s.now()})
+	s.record(db.EventEntityTask, db.EventActionDelete, taskId, db.TaskRowConvert(t))
+}
+
+func (s *Store) TasksCreate(ctx context.Context, userLogin, taskTitle, taskDescription, taskStatus string, DueDate time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.taskCreate(userLogin, taskTitle, taskDescription, taskStatus, DueDate)
+}
+
+func (s *Store) TasksCreateMany(ctx context.Context, userLogin string, tasks []db.TaskModel) ([]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Checked up front so a failure leaves nothing created.
+	_, err := s.userByLogin(userLogin)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tasks {
+		err = taskStatusCheck(string(t.Status))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	taskIds := make([]int64, 0, len(tasks))
+	for _, t := range tasks {
+		taskId, err := s.taskCreate(userLogin, t.Title, t.Description, string(t.Status), t.DueDate)
+		if err != nil {
+			return nil, err
+		}
+		taskIds = append(taskIds, taskId)
+	}
+
+	return taskIds, nil
+}
+
+func (s *Store) Tasks(ctx context.Context, filt filters.Filtering) ([]db.TaskModel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.tasksFilter(filt)
+}
+
+func (s *Store) Task(ctx context.Context, taskId int64) (db.TaskModel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tasks[taskId]
+	if !ok {
+		return db.TaskModel{}, db.ErrNotFound
+	}
+	return t, nil
+}
+
+func (s *Store) TasksByIds(ctx context.Context, taskIds []int64) (map[int64]db.TaskModel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byId := make(map[int64]db.TaskModel, len(taskIds))
+	for _, id := range taskIds {
+		if t, ok := s.tasks[id]; ok {
+			byId[id] = t
+		}
+	}
+	return byId, nil
+}
+
+// TasksEach calls fn without holding the lock, so fn may use the store.
+func (s *Store) TasksEach(ctx context.Context, filt filters.Filtering, fn func(db.TaskModel) error) error {
+	s.mu.Lock()
+	tasks, err := s.tasksFilter(filt)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	for _, t := range tasks {
+		err = fn(t)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) TasksUpdate(ctx context.Context, taskId int64, taskTitle, taskDescription, taskStatus string, DueDate time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := taskStatusCheck(taskStatus)
+	if err != nil {
+		return err
+	}
+
+	t, ok := s.tasks[taskId]
+	if !ok {
+		return db.ErrNotFound
+	}
+	t.Title, t.Description, t.Status, t.DueDate = taskTitle, taskDescription, db.TaskStatus(taskStatus), DueDate
+	s.taskUpdate(t)
+	return nil
+}
+
+func (s *Store) TasksDelete(ctx context.Context, ids []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// One statement in Postgres: a task still commented on fails them all.
+	for _, id := range ids {
+		err := s.taskDeleteCheck(id)
+		if err != nil {
+			return err
+		}
+	}
+	deleted := 0
+	for _, id := range ids {
+		if _, ok := s.tasks[id]; ok {
+			s.taskDelete(id)
+			deleted++
+		}
+	}
+	if deleted == 0 && len(ids) > 0 {
+		return db.ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) TasksCountByStatus(ctx context.Context) (map[db.TaskStatus]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := make(map[db.TaskStatus]int64)
+	for _, t := range s.tasks {
+		counts[t.Status]++
+	}
+	return counts, nil
+}
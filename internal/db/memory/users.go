@@ -0,0 +1,126 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gitlab.com/vitbog/titov-rest/internal/db"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type user struct {
+	db.UserModel
+	password      []byte
+	feedTokenHash string
+}
+
+// userByLogin must be called with mu held.
+func (s *Store) userByLogin(login string) (*user, error) {
+	for _, u := range s.users {
+		if u.Login == login {
+			return u, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: user with given login", db.ErrNotFound)
+}
+
+func (s *Store) Auth(ctx context.Context, login, password string) (string, error) {
+	s.mu.Lock()
+	u, err := s.userByLogin(login)
+	s.mu.Unlock()
+	if err != nil {
+		return "", db.ErrInvalidCredentials
+	}
+
+	err = bcrypt.CompareHashAndPassword(u.password, []byte(password))
+	if err != nil {
+		return "", db.ErrInvalidCredentials
+	}
+	return u.Role, nil
+}
+
+func (s *Store) Register(ctx context.Context, login, password, roleName, fname, lname string) (int64, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return 0, errors.New("failed to hash password")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.userByLogin(login); err == nil {
+		return 0, &db.ConstraintError{Err: db.ErrAlreadyExists, Field: "login"}
+	}
+
+	s.lastUserId++
+	s.users[s.lastUserId] = &user{
+		UserModel: db.UserModel{
+			Id:               s.lastUserId,
+			Login:            login,
+			FName:            fname,
+			LName:            lname,
+			Role:             roleName,
+			DateRegistration: s.now(),
+		},
+		password: hashedPassword,
+	}
+
+	return s.lastUserId, nil
+}
+
+func (s *Store) UsersByIds(ctx context.Context, userIds []int64) (map[int64]db.UserModel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byId := make(map[int64]db.UserModel, len(userIds))
+	for _, id := range userIds {
+		if u, ok := s.users[id]; ok {
+			byId[id] = u.UserModel
+		}
+	}
+	return byId, nil
+}
+
+func (s *Store) UserByLogin(ctx context.Context, login string) (db.UserModel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, err := s.userByLogin(login)
+	if err != nil {
+		return db.UserModel{}, db.ErrNotFound
+	}
+	return u.UserModel, nil
+}
+
+func (s *Store) FeedTokenSet(ctx context.Context, login, feedToken string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, err := s.userByLogin(login)
+	if err != nil {
+		return err
+	}
+
+	hash := db.FeedTokenHash(feedToken)
+	for _, other := range s.users {
+		if other != u && other.feedTokenHash == hash {
+			return &db.ConstraintError{Err: db.ErrAlreadyExists, Field: "feed_token_hash"}
+		}
+	}
+	u.feedTokenHash = hash
+	return nil
+}
+
+func (s *Store) FeedTokenUser(ctx context.Context, feedToken string) (int64, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash := db.FeedTokenHash(feedToken)
+	for _, u := range s.users {
+		if u.feedTokenHash == hash {
+			return u.Id, u.Login, nil
+		}
+	}
+	return 0, "", db.ErrNotFound
+}
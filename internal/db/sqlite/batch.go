@@ -0,0 +1,115 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"gitlab.com/vitbog/titov-rest/internal/db"
+	"gitlab.com/vitbog/titov-rest/internal/filters"
+)
+
+// errBatchFailed rolls back a batch in which some task failed; the errors
+// themselves are in the result.
+var errBatchFailed = errors.New("batch failed")
+
+func (s *Store) TasksBatch(ctx context.Context, ids []int64, filt *filters.Filtering, action db.TaskBatchAction, maxItems int) (db.TaskBatchResult, error) {
+	var result db.TaskBatchResult
+	err := s.inTx(ctx, func(tx *sqlx.Tx) error {
+		if filt != nil {
+			tasks, err := tasksFilter(ctx, tx, *filt)
+			if err != nil {
+				return err
+			}
+			ids = make([]int64, 0, len(tasks))
+			for _, t := range tasks {
+				ids = append(ids, t.Id)
+			}
+		}
+
+		if len(ids) > maxItems {
+			return fmt.Errorf("%w: %d tasks, at most %d allowed", db.ErrBatchTooLarge, len(ids), maxItems)
+		}
+
+		result = db.TaskBatchResult{Ids: ids, Errs: make([]error, len(ids)), Committed: true}
+		for i, id := range ids {
+			err := s.taskBatchApply(ctx, tx, id, action)
+			if err != nil {
+				result.Errs[i] = err
+				result.Committed = false
+			}
+		}
+
+		if !result.Committed {
+			return errBatchFailed
+		}
+		return nil
+	})
+	if errors.Is(err, errBatchFailed) {
+		return result, nil
+	}
+	if err != nil {
+		return db.TaskBatchResult{}, err
+	}
+
+	return result, nil
+}
+
+// taskBatchApply is tasks.tasks_batch_apply. A failed task may leave changes
+// behind, so the batch must then be rolled back.
+func (s *Store) taskBatchApply(ctx context.Context, tx *sqlx.Tx, id int64, action db.TaskBatchAction) error {
+	t, ok, err := taskById(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	switch action.Action {
+	case db.TaskBatchSetStatus:
+		err = taskStatusCheck(action.Status)
+		if err != nil {
+			return err
+		}
+		t.Status = db.TaskStatus(action.Status)
+	case db.TaskBatchAssign:
+		// Like the foreign key, only a row actually updated is checked.
+		if ok {
+			var exists bool
+			err = tx.GetContext(ctx, &exists, `select exists (select 1 from users u where u.id=$1)`, action.IdUser)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				return &db.ConstraintError{Err: db.ErrMissingReference, Field: "id_user"}
+			}
+		}
+		t.IdUser = action.IdUser
+	case db.TaskBatchRelabel:
+		t.Title = action.Title
+	case db.TaskBatchMoveDueDate:
+		switch {
+		case !action.DueDate.IsZero():
+			t.DueDate = action.DueDate
+		case !ok:
+			// Reported as not found below.
+		case t.DueDate.IsZero():
+			return db.ErrNoDueDate
+		case t.DueDate.Add(action.Shift).Before(action.NotBefore):
+			return db.ErrDueDatePast
+		default:
+			t.DueDate = t.DueDate.Add(action.Shift)
+		}
+	case db.TaskBatchDelete:
+		if !ok {
+			return db.ErrNotFound
+		}
+		return s.taskDelete(ctx, tx, t)
+	default:
+		return fmt.Errorf("unknown batch action %s", action.Action)
+	}
+
+	if !ok {
+		return db.ErrNotFound
+	}
+	return s.taskUpdate(ctx, tx, t)
+}
@@ -0,0 +1,135 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"gitlab.com/vitbog/titov-rest/internal/db"
+	"gitlab.com/vitbog/titov-rest/internal/filters"
+)
+
+type commentDb struct {
+	Id        int64  `db:"id"`
+	IdUser    int64  `db:"id_user"`
+	IdTask    int64  `db:"id_task"`
+	Content   string `db:"content"`
+	CreatedAt string `db:"created_at"`
+	UpdatedAt string `db:"updated_at"`
+}
+
+func commentConvertFromDb(c commentDb) (db.CommentModel, error) {
+	createdAt, err := timestampParse(c.CreatedAt)
+	if err != nil {
+		return db.CommentModel{}, err
+	}
+	updatedAt, err := timestampParse(c.UpdatedAt)
+	if err != nil {
+		return db.CommentModel{}, err
+	}
+
+	return db.CommentModel{
+		Id:        c.Id,
+		IdUser:    c.IdUser,
+		IdTask:    c.IdTask,
+		Content:   c.Content,
+		CreatedAt: createdAt,
+		UpdatedAt: updatedAt,
+	}, nil
+}
+
+const commentColumns = "c.id, c.id_user, c.id_task, c.content, c.created_at, c.updated_at"
+
+// commentsSelect runs a query for commentColumns.
+func commentsSelect(ctx context.Context, q sqlx.QueryerContext, query string, args ...interface{}) ([]db.CommentModel, error) {
+	reply := []commentDb{}
+	err := sqlx.SelectContext(ctx, q, &reply, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	convertedComments := make([]db.CommentModel, 0, len(reply))
+	for _, c := range reply {
+		convertedComment, err := commentConvertFromDb(c)
+		if err != nil {
+			return nil, err
+		}
+		convertedComments = append(convertedComments, convertedComment)
+	}
+	return convertedComments, nil
+}
+
+func (s *Store) Comments(ctx context.Context, taskId int64, filt filters.Filtering) ([]db.CommentModel, error) {
+	comments, err := commentsSelect(ctx, &s.Lite, `select `+commentColumns+` from comments c where c.id_task=$1 order by c.id`, taskId)
+	if err != nil {
+		return nil, err
+	}
+
+	comments, err = filters.Apply(filt, comments, db.CommentsAllowedColumns, db.CommentsAllowedColumns, db.CommentColumn)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", db.ErrFiltering, err)
+	}
+	return comments, nil
+}
+
+func (s *Store) Comment(ctx context.Context, commentId int64) (db.CommentModel, error) {
+	comments, err := commentsSelect(ctx, &s.Lite, `select `+commentColumns+` from comments c where c.id=$1`, commentId)
+	if err != nil {
+		return db.CommentModel{}, err
+	}
+	if len(comments) == 0 {
+		return db.CommentModel{}, db.ErrNotFound
+	}
+	return comments[0], nil
+}
+
+// CommentCreate is tasks.comment_create.
+func (s *Store) CommentCreate(ctx context.Context, taskId int64, userLogin, content string) (int64, error) {
+	var commentId int64
+	err := s.inTx(ctx, func(tx *sqlx.Tx) error {
+		userId, err := userIdByLogin(ctx, tx, userLogin)
+		if err != nil {
+			return err
+		}
+		_, ok, err := taskById(ctx, tx, taskId)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return &db.ConstraintError{Err: db.ErrMissingReference, Field: "id_task"}
+		}
+
+		now := timestamp(s.now())
+		err = tx.GetContext(ctx, &commentId, `insert into comments (id_user, id_task, content, created_at, updated_at)
+            values ($1, $2, $3, $4, $5) returning id`,
+			userId, taskId, content, now, now)
+		if err != nil {
+			return err
+		}
+
+		comments, err := commentsSelect(ctx, tx, `select `+commentColumns+` from comments c where c.id=$1`, commentId)
+		if err != nil {
+			return err
+		}
+		return s.record(ctx, tx, db.EventEntityComment, db.EventActionInsert, commentId, comments[0])
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return commentId, nil
+}
+
+func (s *Store) CommentsByTasks(ctx context.Context, taskIds []int64) (map[int64][]db.CommentModel, error) {
+	comments, err := commentsSelect(ctx, &s.Lite, `select `+commentColumns+` from comments c
+        where c.id_task in (select value from json_each($1)) order by c.id_task, c.created_at`, idList(taskIds))
+	if err != nil {
+		return nil, err
+	}
+
+	grouped := make(map[int64][]db.CommentModel, len(taskIds))
+	for _, c := range comments {
+		grouped[c.IdTask] = append(grouped[c.IdTask], c)
+	}
+	return grouped, nil
+}
@@ -0,0 +1,58 @@
+package sqlite
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"gitlab.com/vitbog/titov-rest/internal/db"
+)
+
+type eventDb struct {
+	Id        int64  `db:"id"`
+	Entity    string `db:"entity"`
+	Action    string `db:"action"`
+	IdEntity  int64  `db:"id_entity"`
+	Payload   string `db:"payload"`
+	CreatedAt string `db:"created_at"`
+}
+
+func (s *Store) Events(ctx context.Context, afterId int64, limit int) ([]db.EventModel, error) {
+	reply := []eventDb{}
+	err := s.Lite.SelectContext(ctx, &reply, `select e.id, e.entity, e.action, e.id_entity, e.payload, e.created_at from events e
+        where e.id > $1 order by e.id limit $2`, afterId, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]db.EventModel, 0, len(reply))
+	for _, e := range reply {
+		createdAt, err := timestampParse(e.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, db.EventModel{
+			Id:        e.Id,
+			Entity:    e.Entity,
+			Action:    e.Action,
+			IdEntity:  e.IdEntity,
+			Payload:   json.RawMessage(e.Payload),
+			CreatedAt: createdAt,
+		})
+	}
+	return events, nil
+}
+
+func (s *Store) EventsLastId(ctx context.Context) (int64, error) {
+	var eventId int64
+	err := s.Lite.GetContext(ctx, &eventId, `select coalesce(max(e.id), 0) from events e`)
+	if err != nil {
+		return 0, err
+	}
+	return eventId, nil
+}
+
+func (s *Store) EventsCleanup(ctx context.Context, before time.Time) error {
+	_, err := s.Lite.ExecContext(ctx, `delete from events where created_at < $1`, timestamp(before.UTC()))
+	return err
+}
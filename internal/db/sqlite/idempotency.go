@@ -0,0 +1,101 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"gitlab.com/vitbog/titov-rest/internal/db"
+)
+
+type idempotencyDb struct {
+	RequestHash string         `db:"request_hash"`
+	Status      sql.NullInt64  `db:"status"`
+	Headers     sql.NullString `db:"headers"`
+	Body        []byte         `db:"body"`
+	CreatedAt   string         `db:"created_at"`
+}
+
+// IdempotencyBegin is tasks.idempotency_begin.
+func (s *Store) IdempotencyBegin(ctx context.Context, scope, key, requestHash string, window time.Duration) (db.IdempotencyModel, error) {
+	var result db.IdempotencyModel
+	err := s.inTx(ctx, func(tx *sqlx.Tx) error {
+		now := s.now()
+		_, err := tx.ExecContext(ctx, `delete from idempotency_keys where scope=$1 and key=$2 and created_at < $3`,
+			scope, key, timestamp(now.Add(-window.Truncate(time.Second))))
+		if err != nil {
+			return err
+		}
+
+		inserted, err := tx.ExecContext(ctx, `insert into idempotency_keys (scope, key, request_hash, created_at)
+            values ($1, $2, $3, $4) on conflict do nothing`,
+			scope, key, requestHash, timestamp(now))
+		if err != nil {
+			return err
+		}
+		created, err := inserted.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if created == 1 {
+			result = db.IdempotencyModel{Created: true, RequestHash: requestHash, Headers: map[string]string{}, CreatedAt: now}
+			return nil
+		}
+
+		var stored idempotencyDb
+		err = tx.GetContext(ctx, &stored, `select k.request_hash, k.status, k.headers, k.body, k.created_at from idempotency_keys k
+            where k.scope=$1 and k.key=$2`, scope, key)
+		if err != nil {
+			return err
+		}
+
+		headers := map[string]string{}
+		if stored.Headers.Valid {
+			err = json.Unmarshal([]byte(stored.Headers.String), &headers)
+			if err != nil {
+				return err
+			}
+		}
+		createdAt, err := timestampParse(stored.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		result = db.IdempotencyModel{
+			RequestHash: stored.RequestHash,
+			Status:      int(stored.Status.Int64),
+			Headers:     headers,
+			Body:        stored.Body,
+			CreatedAt:   createdAt,
+		}
+		return nil
+	})
+	if err != nil {
+		return db.IdempotencyModel{}, err
+	}
+
+	return result, nil
+}
+
+func (s *Store) IdempotencyFinish(ctx context.Context, scope, key string, status int, headers map[string]string, body []byte) error {
+	headersJSON, err := json.Marshal(headers)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.Lite.ExecContext(ctx, `update idempotency_keys set status=$1, headers=$2, body=$3 where scope=$4 and key=$5`,
+		status, string(headersJSON), body, scope, key)
+	return err
+}
+
+func (s *Store) IdempotencyRelease(ctx context.Context, scope, key string) error {
+	_, err := s.Lite.ExecContext(ctx, `delete from idempotency_keys where scope=$1 and key=$2 and status is null`, scope, key)
+	return err
+}
+
+func (s *Store) IdempotencyCleanup(ctx context.Context, before time.Time) error {
+	_, err := s.Lite.ExecContext(ctx, `delete from idempotency_keys where created_at < $1`, timestamp(before.UTC()))
+	return err
+}
@@ -0,0 +1,69 @@
+-- The Postgres schema in one SQLite file. Timestamps are text in
+-- timestampLayout, which sorts like the time it holds.
+
+CREATE TABLE IF NOT EXISTS users (
+    id integer primary key autoincrement,
+    login text unique not null,
+    password text not null,
+    f_name text not null,
+    l_name text not null,
+    role text not null,
+    date_registration text not null,
+    feed_token_hash text null unique
+);
+
+CREATE TABLE IF NOT EXISTS tasks (
+    id integer primary key autoincrement,
+    id_user integer not null references users(id),
+    title text not null,
+    description text null,
+    status text null check (status in ('frozen', 'pending', 'in-progress', 'completed')),
+    created_at text not null,
+    due_date text null,
+    updated_at text not null
+);
+
+CREATE INDEX IF NOT EXISTS tasks_updated_at ON tasks (updated_at);
+
+CREATE TABLE IF NOT EXISTS comments (
+    id integer primary key autoincrement,
+    id_user integer not null references users(id),
+    id_task integer not null references tasks(id),
+    content text not null,
+    created_at text not null,
+    updated_at text not null
+);
+
+CREATE INDEX IF NOT EXISTS comments_id_task ON comments (id_task);
+CREATE INDEX IF NOT EXISTS comments_updated_at ON comments (updated_at);
+
+CREATE TABLE IF NOT EXISTS events (
+    id integer primary key autoincrement,
+    entity text not null,
+    action text not null,
+    id_entity integer not null,
+    payload text not null,
+    created_at text not null
+);
+
+CREATE TABLE IF NOT EXISTS tombstones (
+    id integer primary key autoincrement,
+    entity text not null,
+    id_entity integer not null,
+    deleted_at text not null
+);
+
+CREATE INDEX IF NOT EXISTS tombstones_deleted_at ON tombstones (deleted_at);
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    scope text not null,
+    key text not null,
+    request_hash text not null,
+    status integer null,
+    headers text null,
+    body blob null,
+    created_at text not null,
+    primary key (scope, key)
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_created_at ON idempotency_keys (created_at);
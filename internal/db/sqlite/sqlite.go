@@ -0,0 +1,147 @@
+// Package sqlite is a db.Storage in a single SQLite file, for small teams and
+// local development without Postgres. The PL/pgSQL functions and triggers of
+// the Postgres schema are done here in Go, in the same transaction as the
+// change: the checks before writing, change events and tombstones. Filtering
+// goes through filters.Apply like in package memory.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"gitlab.com/vitbog/titov-rest/internal/db"
+	_ "modernc.org/sqlite"
+)
+
+// timestampLayout is how timestamps are stored: fixed width, so comparing
+// the text compares the times.
+const timestampLayout = "2006-01-02 15:04:05.000000"
+
+//go:embed schema.sql
+var schema string
+
+type Store struct {
+	Lite sqlx.DB
+
+	// Now is the clock used for every timestamp, like NOW() in the Postgres
+	// functions.
+	Now func() time.Time
+}
+
+var _ db.Storage = (*Store)(nil)
+
+// New opens the SQLite database at path, creating it and its tables if
+// needed.
+func New(path string) (*Store, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return nil, err
+	}
+
+	dsn := "file:" + path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_txlock=immediate"
+	lite, err := sqlx.Connect("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite has one writer anyway. With one connection the checks made
+	// before writing also see every earlier write, as the Postgres functions
+	// do under their row locks.
+	lite.SetMaxOpenConns(1)
+
+	_, err = lite.Exec(schema)
+	if err != nil {
+		lite.Close()
+		return nil, err
+	}
+
+	return &Store{Lite: *lite, Now: time.Now}, nil
+}
+
+func (s *Store) Ping(ctx context.Context) error {
+	return s.Lite.PingContext(ctx)
+}
+
+func (s *Store) Close() error {
+	return s.Lite.Close()
+}
+
+// now is Now with the precision and zone of a Postgres timestamp without
+// time zone.
+func (s *Store) now() time.Time {
+	return s.Now().UTC().Truncate(time.Microsecond)
+}
+
+// timestamp is t as stored. Like a timestamp without time zone, the zone of
+// t is dropped rather than converted.
+func timestamp(t time.Time) string {
+	return t.Format(timestampLayout)
+}
+
+// nullTimestamp is timestamp with the zero time as NULL.
+func nullTimestamp(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamp(t)
+}
+
+func timestampParse(value string) (time.Time, error) {
+	return time.Parse(timestampLayout, value)
+}
+
+func nullTimestampParse(value sql.NullString) (time.Time, error) {
+	if !value.Valid {
+		return time.Time{}, nil
+	}
+	return timestampParse(value.String)
+}
+
+// idList is an id list for json_each, the SQLite counterpart of pq.Array.
+func idList(values []int64) string {
+	if values == nil {
+		values = []int64{}
+	}
+	encoded, _ := json.Marshal(values)
+	return string(encoded)
+}
+
+// inTx runs fn in a transaction, committed if fn succeeds.
+func (s *Store) inTx(ctx context.Context, fn func(tx *sqlx.Tx) error) error {
+	tx, err := s.Lite.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = fn(tx)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// record adds the event the tasks.events_notify trigger would for row.
+func (s *Store) record(ctx context.Context, tx *sqlx.Tx, entity, action string, idEntity int64, row interface{}) error {
+	payload, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `insert into events (entity, action, id_entity, payload, created_at) values ($1, $2, $3, $4, $5)`,
+		entity, action, idEntity, string(payload), timestamp(s.now()))
+	return err
+}
+
+// tombstone adds the row the tasks.tombstones_create trigger would.
+func (s *Store) tombstone(ctx context.Context, tx *sqlx.Tx, entity string, idEntity int64) error {
+	_, err := tx.ExecContext(ctx, `insert into tombstones (entity, id_entity, deleted_at) values ($1, $2, $3)`,
+		entity, idEntity, timestamp(s.now()))
+	return err
+}
@@ -0,0 +1,115 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"gitlab.com/vitbog/titov-rest/internal/db"
+)
+
+func (s *Store) SyncNow(ctx context.Context) (time.Time, error) {
+	return s.now(), nil
+}
+
+func (s *Store) SyncTasks(ctx context.Context, since, until time.Time) ([]db.TaskModel, error) {
+	return tasksSelect(ctx, &s.Lite, `select `+taskColumns+` from tasks t
+        where t.updated_at > $1 and t.updated_at <= $2 order by t.updated_at, t.id`,
+		timestamp(since.UTC()), timestamp(until.UTC()))
+}
+
+func (s *Store) SyncComments(ctx context.Context, since, until time.Time) ([]db.CommentModel, error) {
+	return commentsSelect(ctx, &s.Lite, `select `+commentColumns+` from comments c
+        where c.updated_at > $1 and c.updated_at <= $2 order by c.updated_at, c.id`,
+		timestamp(since.UTC()), timestamp(until.UTC()))
+}
+
+func (s *Store) SyncTombstones(ctx context.Context, since, until time.Time) ([]db.TombstoneModel, error) {
+	reply := []struct {
+		Entity    string `db:"entity"`
+		IdEntity  int64  `db:"id_entity"`
+		DeletedAt string `db:"deleted_at"`
+	}{}
+	err := s.Lite.SelectContext(ctx, &reply, `select t.entity, t.id_entity, t.deleted_at from tombstones t
+        where t.deleted_at > $1 and t.deleted_at <= $2 order by t.deleted_at, t.id`,
+		timestamp(since.UTC()), timestamp(until.UTC()))
+	if err != nil {
+		return nil, err
+	}
+
+	tombstones := make([]db.TombstoneModel, 0, len(reply))
+	for _, t := range reply {
+		deletedAt, err := timestampParse(t.DeletedAt)
+		if err != nil {
+			return nil, err
+		}
+		tombstones = append(tombstones, db.TombstoneModel{Entity: t.Entity, IdEntity: t.IdEntity, DeletedAt: deletedAt})
+	}
+	return tombstones, nil
+}
+
+func (s *Store) SyncTombstonesCleanup(ctx context.Context, before time.Time) error {
+	_, err := s.Lite.ExecContext(ctx, `delete from tombstones where deleted_at < $1`, timestamp(before.UTC()))
+	return err
+}
+
+// syncTask returns the task and whether a client that last saw it at
+// baseUpdatedAt may change it, as one of the SyncStatus values.
+func syncTask(ctx context.Context, tx *sqlx.Tx, taskId int64, baseUpdatedAt *time.Time) (db.TaskModel, string, error) {
+	t, ok, err := taskById(ctx, tx, taskId)
+	switch {
+	case err != nil:
+		return db.TaskModel{}, "", err
+	case !ok:
+		return db.TaskModel{}, db.SyncStatusNotFound, nil
+	case baseUpdatedAt != nil && t.UpdatedAt.After(*baseUpdatedAt):
+		return db.TaskModel{}, db.SyncStatusConflict, nil
+	}
+	return t, db.SyncStatusApplied, nil
+}
+
+// SyncTaskUpdate is tasks.sync_task_update.
+func (s *Store) SyncTaskUpdate(ctx context.Context, taskId int64, taskTitle, taskDescription, taskStatus string, DueDate time.Time, baseUpdatedAt *time.Time) (string, error) {
+	err := taskStatusCheck(taskStatus)
+	if err != nil {
+		return "", err
+	}
+
+	var status string
+	err = s.inTx(ctx, func(tx *sqlx.Tx) error {
+		var t db.TaskModel
+		var err error
+		t, status, err = syncTask(ctx, tx, taskId, baseUpdatedAt)
+		if err != nil || status != db.SyncStatusApplied {
+			return err
+		}
+
+		t.Title, t.Description, t.Status, t.DueDate = taskTitle, taskDescription, db.TaskStatus(taskStatus), DueDate
+		return s.taskUpdate(ctx, tx, t)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return status, nil
+}
+
+// SyncTaskDelete is tasks.sync_task_delete.
+func (s *Store) SyncTaskDelete(ctx context.Context, taskId int64, baseUpdatedAt *time.Time) (string, error) {
+	var status string
+	err := s.inTx(ctx, func(tx *sqlx.Tx) error {
+		var t db.TaskModel
+		var err error
+		t, status, err = syncTask(ctx, tx, taskId, baseUpdatedAt)
+		if err != nil || status != db.SyncStatusApplied {
+			return err
+		}
+
+		return s.taskDelete(ctx, tx, t)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return status, nil
+}
@@ -0,0 +1,335 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"gitlab.com/vitbog/titov-rest/internal/db"
+	"gitlab.com/vitbog/titov-rest/internal/filters"
+)
+
+type taskDb struct {
+	Id          int64          `db:"id"`
+	IdUser      int64          `db:"id_user"`
+	Title       string         `db:"title"`
+	Description sql.NullString `db:"description"`
+	Status      sql.NullString `db:"status"`
+	CreatedAt   string         `db:"created_at"`
+	DueDate     sql.NullString `db:"due_date"`
+	UpdatedAt   string         `db:"updated_at"`
+}
+
+func taskConvertFromDb(t taskDb) (db.TaskModel, error) {
+	if !db.TaskStatusIsValid(t.Status.String) {
+		return db.TaskModel{}, errors.New("task status is not valid")
+	}
+	createdAt, err := timestampParse(t.CreatedAt)
+	if err != nil {
+		return db.TaskModel{}, err
+	}
+	dueDate, err := nullTimestampParse(t.DueDate)
+	if err != nil {
+		return db.TaskModel{}, err
+	}
+	updatedAt, err := timestampParse(t.UpdatedAt)
+	if err != nil {
+		return db.TaskModel{}, err
+	}
+
+	return db.TaskModel{
+		Id:          t.Id,
+		IdUser:      t.IdUser,
+		Title:       t.Title,
+		Description: t.Description.String,
+		Status:      db.TaskStatus(t.Status.String),
+		CreatedAt:   createdAt,
+		DueDate:     dueDate,
+		UpdatedAt:   updatedAt,
+	}, nil
+}
+
+func tasksConvertFromDb(tasks []taskDb) ([]db.TaskModel, error) {
+	convertedTasks := make([]db.TaskModel, 0, len(tasks))
+	for _, t := range tasks {
+		convertedTask, err := taskConvertFromDb(t)
+		if err != nil {
+			return nil, err
+		}
+		convertedTasks = append(convertedTasks, convertedTask)
+	}
+	return convertedTasks, nil
+}
+
+const taskColumns = "t.id, t.id_user, t.title, t.description, t.status, t.created_at, t.due_date, t.updated_at"
+
+// tasksSelect runs a query for taskColumns.
+func tasksSelect(ctx context.Context, q sqlx.QueryerContext, query string, args ...interface{}) ([]db.TaskModel, error) {
+	reply := []taskDb{}
+	err := sqlx.SelectContext(ctx, q, &reply, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return tasksConvertFromDb(reply)
+}
+
+// taskStatusCheck is the cast to the task_status enum.
+func taskStatusCheck(taskStatus string) error {
+	if !db.TaskStatusIsValid(taskStatus) {
+		return fmt.Errorf("%w: task status %q", db.ErrInvalidValue, taskStatus)
+	}
+	return nil
+}
+
+// tasksFilter reads every task in id order, the order Postgres returns them
+// in without a sort, and applies filt.
+func tasksFilter(ctx context.Context, q sqlx.QueryerContext, filt filters.Filtering) ([]db.TaskModel, error) {
+	tasks, err := tasksSelect(ctx, q, `select `+taskColumns+` from tasks t order by t.id`)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks, err = filters.Apply(filt, tasks, db.TasksAllowedColumns, db.TasksAllowedColumns, db.TaskColumn)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", db.ErrFiltering, err)
+	}
+	return tasks, nil
+}
+
+// taskById returns the task and whether it exists.
+func taskById(ctx context.Context, q sqlx.QueryerContext, taskId int64) (db.TaskModel, bool, error) {
+	tasks, err := tasksSelect(ctx, q, `select `+taskColumns+` from tasks t where t.id=$1`, taskId)
+	if err != nil || len(tasks) == 0 {
+		return db.TaskModel{}, false, err
+	}
+	return tasks[0], true, nil
+}
+
+// taskCreate is tasks.tasks_create.
+func (s *Store) taskCreate(ctx context.Context, tx *sqlx.Tx, userLogin, taskTitle, taskDescription, taskStatus string, DueDate time.Time) (int64, error) {
+	userId, err := userIdByLogin(ctx, tx, userLogin)
+	if err != nil {
+		return 0, err
+	}
+	err = taskStatusCheck(taskStatus)
+	if err != nil {
+		return 0, err
+	}
+
+	now := s.now()
+	t := db.TaskModel{
+		IdUser:      userId,
+		Title:       taskTitle,
+		Description: taskDescription,
+		Status:      db.TaskStatus(taskStatus),
+		CreatedAt:   now,
+		DueDate:     DueDate,
+		UpdatedAt:   now,
+	}
+	err = tx.GetContext(ctx, &t.Id, `insert into tasks (id_user, title, description, status, created_at, due_date, updated_at)
+        values ($1, $2, $3, $4, $5, $6, $7) returning id`,
+		t.IdUser, t.Title, t.Description, string(t.Status), timestamp(t.CreatedAt), nullTimestamp(t.DueDate), timestamp(t.UpdatedAt))
+	if err != nil {
+		return 0, err
+	}
+
+	// Read back as stored, so the payload has the due date without its zone.
+	t, _, err = taskById(ctx, tx, t.Id)
+	if err != nil {
+		return 0, err
+	}
+	err = s.record(ctx, tx, db.EventEntityTask, db.EventActionInsert, t.Id, db.TaskRowConvert(t))
+	if err != nil {
+		return 0, err
+	}
+
+	return t.Id, nil
+}
+
+// taskUpdate stores every column of t as changed and records it.
+func (s *Store) taskUpdate(ctx context.Context, tx *sqlx.Tx, t db.TaskModel) error {
+	err := taskStatusCheck(string(t.Status))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `update tasks set (id_user, title, description, status, due_date, updated_at) =
+        ($1, $2, $3, $4, $5, $6) where id=$7`,
+		t.IdUser, t.Title, t.Description, string(t.Status), nullTimestamp(t.DueDate), timestamp(s.now()), t.Id)
+	if err != nil {
+		return err
+	}
+
+	t, _, err = taskById(ctx, tx, t.Id)
+	if err != nil {
+		return err
+	}
+	return s.record(ctx, tx, db.EventEntityTask, db.EventActionUpdate, t.Id, db.TaskRowConvert(t))
+}
+
+// taskDelete deletes t unless comments still refer to it, the foreign key
+// from comments.
+func (s *Store) taskDelete(ctx context.Context, tx *sqlx.Tx, t db.TaskModel) error {
+	var referenced bool
+	err := tx.GetContext(ctx, &referenced, `select exists (select 1 from comments c where c.id_task=$1)`, t.Id)
+	if err != nil {
+		return err
+	}
+	if referenced {
+		return &db.ConstraintError{Err: db.ErrStillReferenced, Field: "id_task"}
+	}
+
+	_, err = tx.ExecContext(ctx, `delete from tasks where id=$1`, t.Id)
+	if err != nil {
+		return err
+	}
+
+	err = s.tombstone(ctx, tx, db.EventEntityTask, t.Id)
+	if err != nil {
+		return err
+	}
+	return s.record(ctx, tx, db.EventEntityTask, db.EventActionDelete, t.Id, db.TaskRowConvert(t))
+}
+
+func (s *Store) TasksCreate(ctx context.Context, userLogin, taskTitle, taskDescription, taskStatus string, DueDate time.Time) (int64, error) {
+	var taskId int64
+	err := s.inTx(ctx, func(tx *sqlx.Tx) error {
+		var err error
+		taskId, err = s.taskCreate(ctx, tx, userLogin, taskTitle, taskDescription, taskStatus, DueDate)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return taskId, nil
+}
+
+// TasksCreateMany creates all tasks in one transaction: either every task is
+// created or none is.
+func (s *Store) TasksCreateMany(ctx context.Context, userLogin string, tasks []db.TaskModel) ([]int64, error) {
+	taskIds := make([]int64, 0, len(tasks))
+	err := s.inTx(ctx, func(tx *sqlx.Tx) error {
+		for _, t := range tasks {
+			taskId, err := s.taskCreate(ctx, tx, userLogin, t.Title, t.Description, string(t.Status), t.DueDate)
+			if err != nil {
+				return err
+			}
+			taskIds = append(taskIds, taskId)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return taskIds, nil
+}
+
+func (s *Store) Tasks(ctx context.Context, filt filters.Filtering) ([]db.TaskModel, error) {
+	return tasksFilter(ctx, &s.Lite, filt)
+}
+
+func (s *Store) Task(ctx context.Context, taskId int64) (db.TaskModel, error) {
+	t, ok, err := taskById(ctx, &s.Lite, taskId)
+	if err != nil {
+		return db.TaskModel{}, err
+	}
+	if !ok {
+		return db.TaskModel{}, db.ErrNotFound
+	}
+	return t, nil
+}
+
+func (s *Store) TasksByIds(ctx context.Context, taskIds []int64) (map[int64]db.TaskModel, error) {
+	tasks, err := tasksSelect(ctx, &s.Lite, `select `+taskColumns+` from tasks t where t.id in (select value from json_each($1))`, idList(taskIds))
+	if err != nil {
+		return nil, err
+	}
+
+	byId := make(map[int64]db.TaskModel, len(tasks))
+	for _, t := range tasks {
+		byId[t.Id] = t
+	}
+	return byId, nil
+}
+
+// TasksEach reads the matching tasks before calling fn, as filtering is done
+// in Go and the only connection must be free for fn to use the store.
+func (s *Store) TasksEach(ctx context.Context, filt filters.Filtering, fn func(db.TaskModel) error) error {
+	tasks, err := s.Tasks(ctx, filt)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range tasks {
+		err = fn(t)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) TasksUpdate(ctx context.Context, taskId int64, taskTitle, taskDescription, taskStatus string, DueDate time.Time) error {
+	err := taskStatusCheck(taskStatus)
+	if err != nil {
+		return err
+	}
+
+	return s.inTx(ctx, func(tx *sqlx.Tx) error {
+		t, ok, err := taskById(ctx, tx, taskId)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return db.ErrNotFound
+		}
+
+		t.Title, t.Description, t.Status, t.DueDate = taskTitle, taskDescription, db.TaskStatus(taskStatus), DueDate
+		return s.taskUpdate(ctx, tx, t)
+	})
+}
+
+// TasksDelete deletes the tasks in one transaction, like the one statement
+// of tasks.tasks_delete: a task still commented on fails them all.
+func (s *Store) TasksDelete(ctx context.Context, ids []int64) error {
+	return s.inTx(ctx, func(tx *sqlx.Tx) error {
+		tasks, err := tasksSelect(ctx, tx, `select `+taskColumns+` from tasks t where t.id in (select value from json_each($1)) order by t.id`, idList(ids))
+		if err != nil {
+			return err
+		}
+
+		if len(tasks) == 0 && len(ids) > 0 {
+			return db.ErrNotFound
+		}
+
+		for _, t := range tasks {
+			err = s.taskDelete(ctx, tx, t)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Store) TasksCountByStatus(ctx context.Context) (map[db.TaskStatus]int64, error) {
+	reply := []struct {
+		Status sql.NullString `db:"status"`
+		Count  int64          `db:"count"`
+	}{}
+	err := s.Lite.SelectContext(ctx, &reply, `select t.status, count(*) as count from tasks t group by t.status`)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[db.TaskStatus]int64, len(reply))
+	for _, c := range reply {
+		counts[db.TaskStatus(c.Status.String)] = c.Count
+	}
+	return counts, nil
+}
@@ -0,0 +1,165 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"gitlab.com/vitbog/titov-rest/internal/db"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type userDb struct {
+	Id               int64  `db:"id"`
+	Login            string `db:"login"`
+	FName            string `db:"f_name"`
+	LName            string `db:"l_name"`
+	Role             string `db:"role"`
+	DateRegistration string `db:"date_registration"`
+}
+
+func userConvertFromDb(u userDb) (db.UserModel, error) {
+	dateRegistration, err := timestampParse(u.DateRegistration)
+	if err != nil {
+		return db.UserModel{}, err
+	}
+
+	return db.UserModel{
+		Id:               u.Id,
+		Login:            u.Login,
+		FName:            u.FName,
+		LName:            u.LName,
+		Role:             u.Role,
+		DateRegistration: dateRegistration,
+	}, nil
+}
+
+const userColumns = "u.id, u.login, u.f_name, u.l_name, u.role, u.date_registration"
+
+// userIdByLogin is the user lookup the Postgres functions start with.
+func userIdByLogin(ctx context.Context, q sqlx.QueryerContext, login string) (int64, error) {
+	var userId int64
+	err := sqlx.GetContext(ctx, q, &userId, `select u.id from users u where u.login=$1`, login)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("%w: user with given login", db.ErrNotFound)
+	}
+	return userId, err
+}
+
+func (s *Store) Auth(ctx context.Context, login, password string) (string, error) {
+	reply := []struct {
+		Password string `db:"password"`
+		RoleName string `db:"role"`
+	}{}
+
+	err := s.Lite.SelectContext(ctx, &reply, `select u.password, u.role from users u where u.login=$1`, login)
+	if err != nil {
+		return "", err
+	}
+	if len(reply) == 0 {
+		return "", db.ErrInvalidCredentials
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(reply[0].Password), []byte(password))
+	if err != nil {
+		return "", db.ErrInvalidCredentials
+	}
+	return reply[0].RoleName, nil
+}
+
+func (s *Store) Register(ctx context.Context, login, password, roleName, fname, lname string) (int64, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return 0, errors.New("failed to hash password")
+	}
+
+	var userId int64
+	err = s.inTx(ctx, func(tx *sqlx.Tx) error {
+		_, err := userIdByLogin(ctx, tx, login)
+		if err == nil {
+			return &db.ConstraintError{Err: db.ErrAlreadyExists, Field: "login"}
+		}
+
+		return tx.GetContext(ctx, &userId, `insert into users (login, password, role, f_name, l_name, date_registration)
+            values ($1, $2, $3, $4, $5, $6) returning id`,
+			login, string(hashedPassword), roleName, fname, lname, timestamp(s.now()))
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return userId, nil
+}
+
+func (s *Store) UsersByIds(ctx context.Context, userIds []int64) (map[int64]db.UserModel, error) {
+	reply := []userDb{}
+	err := s.Lite.SelectContext(ctx, &reply, `select `+userColumns+` from users u where u.id in (select value from json_each($1))`, idList(userIds))
+	if err != nil {
+		return nil, err
+	}
+
+	byId := make(map[int64]db.UserModel, len(reply))
+	for _, u := range reply {
+		converted, err := userConvertFromDb(u)
+		if err != nil {
+			return nil, err
+		}
+		byId[converted.Id] = converted
+	}
+	return byId, nil
+}
+
+func (s *Store) UserByLogin(ctx context.Context, login string) (db.UserModel, error) {
+	reply := []userDb{}
+	err := s.Lite.SelectContext(ctx, &reply, `select `+userColumns+` from users u where u.login=$1`, login)
+	if err != nil {
+		return db.UserModel{}, err
+	}
+	if len(reply) == 0 {
+		return db.UserModel{}, db.ErrNotFound
+	}
+
+	return userConvertFromDb(reply[0])
+}
+
+func (s *Store) FeedTokenSet(ctx context.Context, login, feedToken string) error {
+	hash := db.FeedTokenHash(feedToken)
+
+	return s.inTx(ctx, func(tx *sqlx.Tx) error {
+		userId, err := userIdByLogin(ctx, tx, login)
+		if err != nil {
+			return err
+		}
+
+		var taken bool
+		err = tx.GetContext(ctx, &taken, `select exists (select 1 from users u where u.feed_token_hash=$1 and u.id<>$2)`, hash, userId)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return &db.ConstraintError{Err: db.ErrAlreadyExists, Field: "feed_token_hash"}
+		}
+
+		_, err = tx.ExecContext(ctx, `update users set feed_token_hash=$1 where id=$2`, hash, userId)
+		return err
+	})
+}
+
+func (s *Store) FeedTokenUser(ctx context.Context, feedToken string) (int64, string, error) {
+	reply := []struct {
+		Id    int64  `db:"id"`
+		Login string `db:"login"`
+	}{}
+
+	err := s.Lite.SelectContext(ctx, &reply, `select u.id, u.login from users u where u.feed_token_hash=$1`, db.FeedTokenHash(feedToken))
+	if err != nil {
+		return 0, "", err
+	}
+	if len(reply) == 0 {
+		return 0, "", db.ErrNotFound
+	}
+
+	return reply[0].Id, reply[0].Login, nil
+}
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"gitlab.com/vitbog/titov-rest/internal/filters"
+)
+
+// TaskRepository stores tasks. Every implementation must behave like the
+// Postgres one, *Db, including how filters.Filtering is applied.
+type TaskRepository interface {
+	TasksCreate(ctx context.Context, userLogin, taskTitle, taskDescription, taskStatus string, DueDate time.Time) (int64, error)
+	TasksCreateMany(ctx context.Context, userLogin string, tasks []TaskModel) ([]int64, error)
+	Tasks(ctx context.Context, filt filters.Filtering) ([]TaskModel, error)
+	Task(ctx context.Context, taskId int64) (TaskModel, error)
+	TasksByIds(ctx context.Context, taskIds []int64) (map[int64]TaskModel, error)
+	TasksEach(ctx context.Context, filt filters.Filtering, fn func(TaskModel) error) error
+	TasksUpdate(ctx context.Context, taskId int64, taskTitle, taskDescription, taskStatus string, DueDate time.Time) error
+	TasksDelete(ctx context.Context, ids []int64) error
+	TasksBatch(ctx context.Context, ids []int64, filt *filters.Filtering, action TaskBatchAction, maxItems int) (TaskBatchResult, error)
+	TasksCountByStatus(ctx context.Context) (map[TaskStatus]int64, error)
+}
+
+type CommentRepository interface {
+	Comments(ctx context.Context, taskId int64, filt filters.Filtering) ([]CommentModel, error)
+	Comment(ctx context.Context, commentId int64) (CommentModel, error)
+	CommentCreate(ctx context.Context, taskId int64, userLogin, content string) (int64, error)
+	CommentsByTasks(ctx context.Context, taskIds []int64) (map[int64][]CommentModel, error)
+}
+
+type UserRepository interface {
+	Auth(ctx context.Context, login, password string) (string, error)
+	Register(ctx context.Context, login, password, roleName, fname, lname string) (int64, error)
+	UsersByIds(ctx context.Context, userIds []int64) (map[int64]UserModel, error)
+	UserByLogin(ctx context.Context, login string) (UserModel, error)
+	FeedTokenSet(ctx context.Context, login, feedToken string) error
+	FeedTokenUser(ctx context.Context, feedToken string) (int64, string, error)
+}
+
+// EventRepository reads the change events recorded for tasks and comments.
+type EventRepository interface {
+	Events(ctx context.Context, afterId int64, limit int) ([]EventModel, error)
+	EventsLastId(ctx context.Context) (int64, error)
+	EventsCleanup(ctx context.Context, before time.Time) error
+}
+
+type SyncRepository interface {
+	SyncNow(ctx context.Context) (time.Time, error)
+	SyncTasks(ctx context.Context, since, until time.Time) ([]TaskModel, error)
+	SyncComments(ctx context.Context, since, until time.Time) ([]CommentModel, error)
+	SyncTombstones(ctx context.Context, since, until time.Time) ([]TombstoneModel, error)
+	SyncTombstonesCleanup(ctx context.Context, before time.Time) error
+	SyncTaskUpdate(ctx context.Context, taskId int64, taskTitle, taskDescription, taskStatus string, DueDate time.Time, baseUpdatedAt *time.Time) (string, error)
+	SyncTaskDelete(ctx context.Context, taskId int64, baseUpdatedAt *time.Time) (string, error)
+}
+
+type IdempotencyRepository interface {
+	IdempotencyBegin(ctx context.Context, scope, key, requestHash string, window time.Duration) (IdempotencyModel, error)
+	IdempotencyFinish(ctx context.Context, scope, key string, status int, headers map[string]string, body []byte) error
+	IdempotencyRelease(ctx context.Context, scope, key string) error
+	IdempotencyCleanup(ctx context.Context, before time.Time) error
+}
+
+// Storage is everything the server keeps, see internal/db/memory for the
+// implementation that needs no database.
+type Storage interface {
+	TaskRepository
+	CommentRepository
+	UserRepository
+	EventRepository
+	SyncRepository
+	IdempotencyRepository
+	// Ping checks the storage can be reached.
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+var _ Storage = (*Db)(nil)
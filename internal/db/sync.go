@@ -0,0 +1,147 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	SyncStatusApplied  = "applied"
+	SyncStatusConflict = "conflict"
+	SyncStatusNotFound = "not_found"
+)
+
+type TombstoneModel struct {
+	Entity    string    `json:"entity" db:"entity"`
+	IdEntity  int64     `json:"id_entity" db:"id_entity"`
+	DeletedAt time.Time `json:"deleted_at" db:"deleted_at"`
+}
+
+// SyncNow returns the database clock, which is what updated_at and
+// deleted_at are stamped with, so sync tokens never depend on the app clock.
+func (db *Db) SyncNow(ctx context.Context) (time.Time, error) {
+	ctx, span := spanStart(ctx, "SyncNow", "tasks.sync_now")
+	defer span.End()
+
+	schema := "tasks"
+	query := fmt.Sprintf("SELECT %s.sync_now()", schema)
+	var now time.Time
+
+	err := db.Pg.GetContext(ctx, &now, query)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return now, nil
+}
+
+func (db *Db) SyncTasks(ctx context.Context, since, until time.Time) ([]TaskModel, error) {
+	ctx, span := spanStart(ctx, "SyncTasks", "tasks.sync_tasks")
+	defer span.End()
+
+	schema := "tasks"
+	query := fmt.Sprintf("SELECT t.id, t.id_user, t.title, t.description, t.status, t.created_at, t.due_date, t.updated_at from %s.sync_tasks($1, $2) t", schema)
+
+	reply := []TaskDb{}
+	err := db.Pg.SelectContext(ctx, &reply, query, since, until)
+	if err != nil {
+		return nil, err
+	}
+
+	converted, err := db.TasksConvertFromDb(reply)
+	if err != nil {
+		return nil, err
+	}
+
+	return converted, nil
+}
+
+func (db *Db) SyncComments(ctx context.Context, since, until time.Time) ([]CommentModel, error) {
+	ctx, span := spanStart(ctx, "SyncComments", "tasks.sync_comments")
+	defer span.End()
+
+	schema := "tasks"
+	query := fmt.Sprintf("SELECT c.id, c.id_user, c.id_task, c.content, c.created_at, c.updated_at from %s.sync_comments($1, $2) c", schema)
+
+	reply := []CommentDb{}
+	err := db.Pg.SelectContext(ctx, &reply, query, since, until)
+	if err != nil {
+		return nil, err
+	}
+
+	converted, err := db.CommentsConvertFromDb(reply)
+	if err != nil {
+		return nil, err
+	}
+
+	return converted, nil
+}
+
+func (db *Db) SyncTombstones(ctx context.Context, since, until time.Time) ([]TombstoneModel, error) {
+	ctx, span := spanStart(ctx, "SyncTombstones", "tasks.sync_tombstones")
+	defer span.End()
+
+	schema := "tasks"
+	query := fmt.Sprintf("SELECT t.entity, t.id_entity, t.deleted_at from %s.sync_tombstones($1, $2) t", schema)
+
+	reply := []TombstoneModel{}
+	err := db.Pg.SelectContext(ctx, &reply, query, since, until)
+	if err != nil {
+		return nil, err
+	}
+
+	return reply, nil
+}
+
+// SyncTombstonesCleanup removes the tombstones of rows deleted before before.
+func (db *Db) SyncTombstonesCleanup(ctx context.Context, before time.Time) error {
+	ctx, span := spanStart(ctx, "SyncTombstonesCleanup", "tasks.sync_tombstones_cleanup")
+	defer span.End()
+
+	schema := "tasks"
+	query := fmt.Sprintf("CALL %s.sync_tombstones_cleanup($1)", schema)
+
+	_, err := db.Pg.ExecContext(ctx, query, before)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SyncTaskUpdate updates the task only if it was not changed after
+// baseUpdatedAt, and reports one of the SyncStatus values. A nil
+// baseUpdatedAt overwrites unconditionally.
+func (db *Db) SyncTaskUpdate(ctx context.Context, taskId int64, taskTitle, taskDescription, taskStatus string, DueDate time.Time, baseUpdatedAt *time.Time) (string, error) {
+	ctx, span := spanStart(ctx, "SyncTaskUpdate", "tasks.sync_task_update")
+	defer span.End()
+
+	schema := "tasks"
+	query := fmt.Sprintf("SELECT %s.sync_task_update($1, $2, $3, $4, $5, $6)", schema)
+	var status string
+
+	err := db.Pg.GetContext(ctx, &status, query, taskId, taskTitle, taskDescription, taskStatus, DueDate, baseUpdatedAt)
+	if err != nil {
+		return "", err
+	}
+
+	return status, nil
+}
+
+// SyncTaskDelete is the delete counterpart of SyncTaskUpdate.
+func (db *Db) SyncTaskDelete(ctx context.Context, taskId int64, baseUpdatedAt *time.Time) (string, error) {
+	ctx, span := spanStart(ctx, "SyncTaskDelete", "tasks.sync_task_delete")
+	defer span.End()
+
+	schema := "tasks"
+	query := fmt.Sprintf("SELECT %s.sync_task_delete($1, $2)", schema)
+	var status string
+
+	err := db.Pg.GetContext(ctx, &status, query, taskId, baseUpdatedAt)
+	if err != nil {
+		return "", err
+	}
+
+	return status, nil
+}
@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -19,6 +20,9 @@ const (
 	TaskStatusCompleted  = "completed"
 )
 
+// tasksEachChunk is how many tasks TasksEach loads per query.
+const tasksEachChunk = 500
+
 func TaskStatusIsValid(candidate string) bool {
 	return candidate == TaskStatusFrozen || candidate == TaskStatusPending || candidate == TaskStatusInProgress || candidate == TaskStatusCompleted
 }
@@ -78,15 +82,77 @@ func (db *Db) TasksConvertFromDb(tasks []TaskDb) ([]TaskModel, error) {
 }
 
 var (
-	glTasksAllowedColumns = []string{"id", "id_user", "title", "description", "status", "created_at", "updated_at", "due_date", "updated_at"}
+	TasksAllowedColumns = []string{"id", "id_user", "title", "description", "status", "created_at", "updated_at", "due_date", "updated_at"}
 )
 
-func (db *Db) TasksCreate(userLogin, taskTitle, taskDescription, taskStatus string, DueDate time.Time) (int64, error) {
+// TaskColumn returns the named column of t as filters.Apply takes it, for
+// storages filtering in Go.
+func TaskColumn(t TaskModel, name string) interface{} {
+	switch name {
+	case "id":
+		return t.Id
+	case "id_user":
+		return t.IdUser
+	case "title":
+		return t.Title
+	case "description":
+		return t.Description
+	case "status":
+		return string(t.Status)
+	case "created_at":
+		return t.CreatedAt
+	case "due_date":
+		return nullTime(t.DueDate)
+	case "updated_at":
+		return t.UpdatedAt
+	}
+	return nil
+}
+
+// TaskRow is a task as row_to_json writes it in event payloads, with a NULL
+// due date.
+type TaskRow struct {
+	Id          int64       `json:"id"`
+	IdUser      int64       `json:"id_user"`
+	Title       string      `json:"title"`
+	Description string      `json:"description"`
+	Status      string      `json:"status"`
+	CreatedAt   time.Time   `json:"created_at"`
+	DueDate     interface{} `json:"due_date"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+func TaskRowConvert(t TaskModel) TaskRow {
+	return TaskRow{
+		Id:          t.Id,
+		IdUser:      t.IdUser,
+		Title:       t.Title,
+		Description: t.Description,
+		Status:      string(t.Status),
+		CreatedAt:   t.CreatedAt,
+		DueDate:     nullTime(t.DueDate),
+		UpdatedAt:   t.UpdatedAt,
+	}
+}
+
+// nullTime is a timestamp column value for filters.Apply and payloads: the
+// zero time is NULL.
+func nullTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
+
+func (db *Db) TasksCreate(ctx context.Context, userLogin, taskTitle, taskDescription, taskStatus string, DueDate time.Time) (int64, error) {
+	ctx, span := spanStart(ctx, "TasksCreate", "tasks.tasks_create")
+	defer span.End()
+
 	schema := "tasks"
 	query := fmt.Sprintf("SELECT %s.tasks_create($1, $2, $3, $4, $5)", schema)
 	var taskId int64
 
-	err := db.Pg.Get(&taskId, query, userLogin, taskTitle, taskDescription, taskStatus, DueDate)
+	err := db.Pg.GetContext(ctx, &taskId, query, userLogin, taskTitle, taskDescription, taskStatus, DueDate)
 	if err != nil {
 		return 0, err
 	}
@@ -94,17 +160,37 @@ func (db *Db) TasksCreate(userLogin, taskTitle, taskDescription, taskStatus stri
 	return taskId, nil
 }
 
-func (db *Db) Tasks(filt filters.Filtering) ([]TaskModel, error) {
+// tasksQuery builds the query of the tasks matching filt, selecting columns
+// or, when none are given, every column of TaskDb.
+func (db *Db) tasksQuery(ctx context.Context, filt filters.Filtering, columns ...string) (string, []interface{}, error) {
 	schema := "tasks"
 	query := fmt.Sprintf("SELECT t.id, t.id_user, t.title, t.description, t.status, t.created_at, t.due_date, t.updated_at from %s.tasks_list() t", schema)
 
-	filterQuery, args, err := filt.Filter(query, 0, glTasksAllowedColumns, "id", "id_user", "title", "description", "status", "created_at", "updated_at", "due_date", "updated_at")
+	_, span := filterSpanStart(ctx)
+	defer span.End()
+
+	if len(columns) == 0 {
+		columns = []string{"id", "id_user", "title", "description", "status", "created_at", "updated_at", "due_date", "updated_at"}
+	}
+	filterQuery, args, err := filt.Filter(query, 0, TasksAllowedColumns, columns...)
 	if err != nil {
-		return nil, fmt.Errorf("error filtering: %v", err)
+		return "", nil, fmt.Errorf("%w: %v", ErrFiltering, err)
+	}
+
+	return filterQuery, args, nil
+}
+
+func (db *Db) Tasks(ctx context.Context, filt filters.Filtering) ([]TaskModel, error) {
+	ctx, span := spanStart(ctx, "Tasks", "tasks.tasks_list")
+	defer span.End()
+
+	filterQuery, args, err := db.tasksQuery(ctx, filt)
+	if err != nil {
+		return nil, err
 	}
 
 	reply := []TaskDb{}
-	err = db.Pg.Select(&reply, filterQuery, args...)
+	err = db.Pg.SelectContext(ctx, &reply, filterQuery, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -117,26 +203,190 @@ func (db *Db) Tasks(filt filters.Filtering) ([]TaskModel, error) {
 	return converted, nil
 }
 
-func (db *Db) TasksUpdate(taskId int64, taskTitle, taskDescription, taskStatus string, DueDate time.Time) error {
+// Task returns the task with the given id or ErrNotFound.
+func (db *Db) Task(ctx context.Context, taskId int64) (TaskModel, error) {
+	ctx, span := spanStart(ctx, "Task", "tasks.tasks_list")
+	defer span.End()
+
+	tasks, err := db.Tasks(ctx, filters.Filtering{Filters: []filters.Filter{{FieldName: "id", Equals: taskId}}})
+	if err != nil {
+		return TaskModel{}, err
+	}
+	if len(tasks) == 0 {
+		return TaskModel{}, ErrNotFound
+	}
+
+	return tasks[0], nil
+}
+
+// TasksByIds returns the tasks with the given ids in one query, by id.
+func (db *Db) TasksByIds(ctx context.Context, taskIds []int64) (map[int64]TaskModel, error) {
+	ctx, span := spanStart(ctx, "TasksByIds", "tasks.tasks_list_by_ids")
+	defer span.End()
+
 	schema := "tasks"
-	query := fmt.Sprintf("CALL %s.tasks_update($1, $2, $3, $4, $5)", schema)
+	query := fmt.Sprintf("SELECT t.id, t.id_user, t.title, t.description, t.status, t.created_at, t.due_date, t.updated_at from %s.tasks_list_by_ids($1) t", schema)
+
+	reply := []TaskDb{}
+	err := db.Pg.SelectContext(ctx, &reply, query, pq.Array(taskIds))
+	if err != nil {
+		return nil, err
+	}
+
+	converted, err := db.TasksConvertFromDb(reply)
+	if err != nil {
+		return nil, err
+	}
+
+	byId := make(map[int64]TaskModel, len(converted))
+	for _, t := range converted {
+		byId[t.Id] = t
+	}
+
+	return byId, nil
+}
+
+// TasksEach calls fn for every task matching filt. Only the matching ids are
+// read up front; the tasks are then loaded tasksEachChunk at a time, so large
+// result sets are never held in memory at once and no cursor is left open
+// while fn runs, leaving fn free to query the pool itself.
+func (db *Db) TasksEach(ctx context.Context, filt filters.Filtering, fn func(TaskModel) error) error {
+	ctx, span := spanStart(ctx, "TasksEach", "tasks.tasks_list")
+	defer span.End()
+
+	idsQuery, args, err := db.tasksQuery(ctx, filt, "id")
+	if err != nil {
+		return err
+	}
 
-	_, err := db.Pg.Exec(query, taskId, taskTitle, taskDescription, taskStatus, DueDate)
+	ids := []int64{}
+	err = db.Pg.SelectContext(ctx, &ids, idsQuery, args...)
 	if err != nil {
 		return err
 	}
 
+	for len(ids) > 0 {
+		chunk := ids[:min(tasksEachChunk, len(ids))]
+		ids = ids[len(chunk):]
+
+		byId, err := db.TasksByIds(ctx, chunk)
+		if err != nil {
+			return err
+		}
+
+		for _, id := range chunk {
+			t, ok := byId[id]
+			if !ok {
+				// Deleted since the ids were read.
+				continue
+			}
+			err = fn(t)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// TasksUpdate replaces the fields of the task, or reports ErrNotFound when
+// there is no such task.
+func (db *Db) TasksUpdate(ctx context.Context, taskId int64, taskTitle, taskDescription, taskStatus string, DueDate time.Time) error {
+	ctx, span := spanStart(ctx, "TasksUpdate", "tasks.tasks_update")
+	defer span.End()
+
+	schema := "tasks"
+	query := fmt.Sprintf("SELECT %s.tasks_update($1, $2, $3, $4, $5)", schema)
+	var updated int64
+
+	err := db.Pg.GetContext(ctx, &updated, query, taskId, taskTitle, taskDescription, taskStatus, DueDate)
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return ErrNotFound
+	}
+
 	return nil
 }
 
-func (db *Db) TasksDelete(ids []int64) error {
+// TasksDelete deletes the tasks with the given ids, or reports ErrNotFound
+// when none of them exists.
+func (db *Db) TasksDelete(ctx context.Context, ids []int64) error {
+	ctx, span := spanStart(ctx, "TasksDelete", "tasks.tasks_delete")
+	defer span.End()
+
 	schema := "tasks"
-	query := fmt.Sprintf("CALL %s.tasks_delete($1)", schema)
+	query := fmt.Sprintf("SELECT %s.tasks_delete($1)", schema)
+	var deleted int64
 
-	_, err := db.Pg.Exec(query, pq.Array(ids))
+	err := db.Pg.GetContext(ctx, &deleted, query, pq.Array(ids))
 	if err != nil {
 		return err
 	}
+	if deleted == 0 && len(ids) > 0 {
+		return ErrNotFound
+	}
 
 	return nil
 }
+
+// TasksCreateMany creates all tasks in one transaction: either every task is
+// created or none is.
+func (db *Db) TasksCreateMany(ctx context.Context, userLogin string, tasks []TaskModel) ([]int64, error) {
+	ctx, span := spanStart(ctx, "TasksCreateMany", "tasks.tasks_create")
+	defer span.End()
+
+	schema := "tasks"
+	query := fmt.Sprintf("SELECT %s.tasks_create($1, $2, $3, $4, $5)", schema)
+	taskIds := make([]int64, 0, len(tasks))
+
+	tx, err := db.Pg.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	for _, t := range tasks {
+		var taskId int64
+		err = tx.GetContext(ctx, &taskId, query, userLogin, t.Title, t.Description, string(t.Status), t.DueDate)
+		if err != nil {
+			return nil, err
+		}
+		taskIds = append(taskIds, taskId)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return taskIds, nil
+}
+
+// TasksCountByStatus returns how many tasks there are of each status.
+// Statuses without tasks are left out.
+func (db *Db) TasksCountByStatus(ctx context.Context) (map[TaskStatus]int64, error) {
+	ctx, span := spanStart(ctx, "TasksCountByStatus", "tasks.tasks_count_by_status")
+	defer span.End()
+
+	schema := "tasks"
+	query := fmt.Sprintf("SELECT c.status, c.count from %s.tasks_count_by_status() c", schema)
+
+	reply := []struct {
+		Status sql.NullString `db:"status"`
+		Count  int64          `db:"count"`
+	}{}
+	err := db.Pg.SelectContext(ctx, &reply, query)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[TaskStatus]int64, len(reply))
+	for _, c := range reply {
+		counts[TaskStatus(c.Status.String)] = c.Count
+	}
+
+	return counts, nil
+}
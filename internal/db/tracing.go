@@ -0,0 +1,24 @@
+package db
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("gitlab.com/vitbog/titov-rest/internal/db")
+
+// spanStart starts the span of the Db method, which calls the SQL function
+// named with its schema, e.g. tasks.tasks_create.
+func spanStart(ctx context.Context, method, function string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "Db."+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemNamePostgreSQL, semconv.DBStoredProcedureName(function)))
+}
+
+// filterSpanStart starts the span of building a query from filters.Filtering.
+func filterSpanStart(ctx context.Context) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "filters.Filtering.Filter")
+}
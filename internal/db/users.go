@@ -0,0 +1,101 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type UserModel struct {
+	Id               int64     `json:"id" db:"id"`
+	Login            string    `json:"login" db:"login"`
+	FName            string    `json:"fname" db:"f_name"`
+	LName            string    `json:"lname" db:"l_name"`
+	Role             string    `json:"role" db:"role"`
+	DateRegistration time.Time `json:"date_registration" db:"date_registration"`
+}
+
+type UserDb struct {
+	Id               int64          `json:"id" db:"id"`
+	Login            string         `json:"login" db:"login"`
+	FName            sql.NullString `json:"fname" db:"f_name"`
+	LName            sql.NullString `json:"lname" db:"l_name"`
+	Role             sql.NullString `json:"role" db:"role"`
+	DateRegistration sql.NullTime   `json:"date_registration" db:"date_registration"`
+}
+
+func (db *Db) UserConvertFromDb(u UserDb) (UserModel, error) {
+	return UserModel{
+		Id:               u.Id,
+		Login:            u.Login,
+		FName:            u.FName.String,
+		LName:            u.LName.String,
+		Role:             u.Role.String,
+		DateRegistration: u.DateRegistration.Time,
+	}, nil
+}
+
+func (db *Db) UsersConvertFromDb(users []UserDb) ([]UserModel, error) {
+	convertedUsers := make([]UserModel, 0, len(users))
+	for _, u := range users {
+		convertedUser, err := db.UserConvertFromDb(u)
+		if err != nil {
+			return nil, err
+		}
+
+		convertedUsers = append(convertedUsers, convertedUser)
+	}
+
+	return convertedUsers, nil
+}
+
+// UsersByIds returns the users with the given ids in one query, by id.
+// Passwords are never read.
+func (db *Db) UsersByIds(ctx context.Context, userIds []int64) (map[int64]UserModel, error) {
+	ctx, span := spanStart(ctx, "UsersByIds", "users.users_list_by_ids")
+	defer span.End()
+
+	schema := "users"
+	query := fmt.Sprintf("SELECT u.id, u.login, u.f_name, u.l_name, u.role, u.date_registration from %s.users_list_by_ids($1) u", schema)
+
+	reply := []UserDb{}
+	err := db.Pg.SelectContext(ctx, &reply, query, pq.Array(userIds))
+	if err != nil {
+		return nil, err
+	}
+
+	converted, err := db.UsersConvertFromDb(reply)
+	if err != nil {
+		return nil, err
+	}
+
+	byId := make(map[int64]UserModel, len(converted))
+	for _, u := range converted {
+		byId[u.Id] = u
+	}
+
+	return byId, nil
+}
+
+// UserByLogin returns the user with the given login or ErrNotFound.
+func (db *Db) UserByLogin(ctx context.Context, login string) (UserModel, error) {
+	ctx, span := spanStart(ctx, "UserByLogin", "users.users_get_by_login")
+	defer span.End()
+
+	schema := "users"
+	query := fmt.Sprintf("SELECT u.id, u.login, u.f_name, u.l_name, u.role, u.date_registration from %s.users_get_by_login($1) u", schema)
+
+	reply := []UserDb{}
+	err := db.Pg.SelectContext(ctx, &reply, query, login)
+	if err != nil {
+		return UserModel{}, err
+	}
+	if len(reply) == 0 {
+		return UserModel{}, ErrNotFound
+	}
+
+	return db.UserConvertFromDb(reply[0])
+}
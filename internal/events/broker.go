@@ -0,0 +1,211 @@
+package events
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lib/pq"
+	"gitlab.com/vitbog/titov-rest/internal/db"
+)
+
+const (
+	Channel = "tasks_events"
+
+	// Retention is how long events are kept for Last-Event-ID resumption.
+	Retention = 24 * time.Hour
+
+	fetchLimit        = 500
+	subscriberBuffer  = 64
+	pingInterval      = 90 * time.Second
+	cleanupInterval   = time.Hour
+	pollInterval      = time.Second
+	minReconnectDelay = 10 * time.Second
+	maxReconnectDelay = time.Minute
+)
+
+// Broker listens for notifications sent by the tasks.events_notify trigger and
+// fans the stored events out to subscribers. Every app instance runs its own
+// broker, so a change made through any instance reaches all streams. Storages
+// other than Postgres have no notifications and are only polled.
+type Broker struct {
+	Db       db.EventRepository
+	listener *pq.Listener
+
+	mu          sync.Mutex
+	subscribers map[chan db.EventModel]struct{}
+	lastId      int64
+
+	done    chan struct{}
+	wg      sync.WaitGroup
+	running atomic.Bool
+}
+
+// New starts a broker for d. An empty pgConnectionString polls d instead of
+// listening for notifications.
+func New(d db.EventRepository, pgConnectionString string) (*Broker, error) {
+	lastId, err := d.EventsLastId(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Broker{
+		Db:          d,
+		subscribers: make(map[chan db.EventModel]struct{}),
+		lastId:      lastId,
+		done:        make(chan struct{}),
+	}
+
+	if pgConnectionString != "" {
+		b.listener, err = listen(pgConnectionString)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	b.wg.Add(1)
+	b.running.Store(true)
+	go b.run()
+
+	return b, nil
+}
+
+func listen(pgConnectionString string) (*pq.Listener, error) {
+	listener := pq.NewListener(pgConnectionString, minReconnectDelay, maxReconnectDelay, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			slog.Error("events listener", "error", err)
+		}
+	})
+	err := listener.Listen(Channel)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	return listener, nil
+}
+
+// Subscribe registers a new subscriber. The channel is closed when the
+// subscriber falls too far behind or the broker is closed; clients are then
+// expected to reconnect with Last-Event-ID.
+func (b *Broker) Subscribe() (<-chan db.EventModel, func()) {
+	ch := make(chan db.EventModel, subscriberBuffer)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	unsubscribe := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+
+	return ch, unsubscribe
+}
+
+func (b *Broker) Close() error {
+	close(b.done)
+	var err error
+	if b.listener != nil {
+		err = b.listener.Close()
+	}
+	b.wg.Wait()
+
+	b.mu.Lock()
+	for ch := range b.subscribers {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+	b.mu.Unlock()
+
+	return err
+}
+
+// Running reports whether the broker still fetches and broadcasts events.
+func (b *Broker) Running() bool {
+	return b.running.Load()
+}
+
+func (b *Broker) run() {
+	defer b.wg.Done()
+	defer b.running.Store(false)
+
+	// Without a listener notify stays nil and never fires. Events held back
+	// behind a running transaction are not notified again when it ends, so
+	// the broker polls with a listener too.
+	var notify chan *pq.Notification
+	if b.listener != nil {
+		notify = b.listener.Notify
+	}
+
+	poll := time.NewTicker(pollInterval)
+	defer poll.Stop()
+	ping := time.NewTicker(pingInterval)
+	defer ping.Stop()
+	cleanup := time.NewTicker(cleanupInterval)
+	defer cleanup.Stop()
+
+	for {
+		select {
+		case <-b.done:
+			return
+		case <-notify:
+			// A nil notification means the connection was re-established and
+			// notifications may have been lost, so we fetch in every case.
+			b.fetch()
+		case <-poll.C:
+			b.fetch()
+		case <-ping.C:
+			if b.listener != nil {
+				err := b.listener.Ping()
+				if err != nil {
+					slog.Error("pinging events listener", "error", err)
+				}
+			}
+		case <-cleanup.C:
+			err := b.Db.EventsCleanup(context.Background(), time.Now().Add(-Retention))
+			if err != nil {
+				slog.Error("events cleanup", "error", err)
+			}
+		}
+	}
+}
+
+func (b *Broker) fetch() {
+	for {
+		events, err := b.Db.Events(context.Background(), b.lastId, fetchLimit)
+		if err != nil {
+			slog.Error("fetching events", "error", err)
+			return
+		}
+
+		for _, e := range events {
+			b.broadcast(e)
+			b.lastId = e.Id
+		}
+
+		if len(events) < fetchLimit {
+			return
+		}
+	}
+}
+
+func (b *Broker) broadcast(e db.EventModel) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- e:
+		default:
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
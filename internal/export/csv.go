@@ -0,0 +1,39 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+
+	"gitlab.com/vitbog/titov-rest/internal/db"
+)
+
+const CSVContentType = "text/csv; charset=utf-8"
+
+type CSV struct {
+	w    *csv.Writer
+	opts Options
+}
+
+func NewCSV(w io.Writer, opts Options) *CSV {
+	writer := csv.NewWriter(w)
+	writer.Comma = opts.Delimiter
+
+	return &CSV{w: writer, opts: opts}
+}
+
+func (c *CSV) WriteHeader() error {
+	return c.w.Write(c.opts.Header())
+}
+
+func (c *CSV) Write(t db.TaskModel, comments []db.CommentModel) error {
+	return c.w.Write(c.opts.Row(t, comments))
+}
+
+func (c *CSV) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *CSV) Close() error {
+	return c.Flush()
+}
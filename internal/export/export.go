@@ -0,0 +1,233 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata"
+
+	"gitlab.com/vitbog/titov-rest/internal/db"
+)
+
+const (
+	DateFormatRFC3339     = "rfc3339"
+	DateFormatRFC3339Nano = "rfc3339nano"
+	DateFormatDate        = "date"
+	DateFormatDateTime    = "datetime"
+	DateFormatUnix        = "unix"
+)
+
+var dateFormatLayouts = map[string]string{
+	DateFormatRFC3339:     time.RFC3339,
+	DateFormatRFC3339Nano: time.RFC3339Nano,
+	DateFormatDate:        time.DateOnly,
+	DateFormatDateTime:    time.DateTime,
+}
+
+// Column is one exportable task field. Name is what clients pass in the
+// columns parameter, Label is the default header and matches the field name
+// gocsv used to write, so default exports can be imported back as they are.
+// Value returns an int64, a string or a time.Time.
+type Column struct {
+	Name  string
+	Label string
+	Value func(t db.TaskModel) interface{}
+}
+
+var TaskColumns = []Column{
+	{Name: "id", Label: "Id", Value: func(t db.TaskModel) interface{} { return t.Id }},
+	{Name: "id_user", Label: "IdUser", Value: func(t db.TaskModel) interface{} { return t.IdUser }},
+	{Name: "title", Label: "Title", Value: func(t db.TaskModel) interface{} { return t.Title }},
+	{Name: "description", Label: "Description", Value: func(t db.TaskModel) interface{} { return t.Description }},
+	{Name: "status", Label: "Status", Value: func(t db.TaskModel) interface{} { return string(t.Status) }},
+	{Name: "created_at", Label: "CreatedAt", Value: func(t db.TaskModel) interface{} { return t.CreatedAt }},
+	{Name: "due_date", Label: "DueDate", Value: func(t db.TaskModel) interface{} { return t.DueDate }},
+	{Name: "updated_at", Label: "UpdatedAt", Value: func(t db.TaskModel) interface{} { return t.UpdatedAt }},
+}
+
+// CommentsLabel is the header of the extra column tabular formats get when
+// comments are included.
+const CommentsLabel = "Comments"
+
+func TaskColumn(name string) (Column, bool) {
+	for _, c := range TaskColumns {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return Column{}, false
+}
+
+type Options struct {
+	Columns    []Column
+	Labels     []string
+	Delimiter  rune
+	DateFormat string
+	Location   *time.Location
+	Filename   string
+	Comments   bool
+}
+
+// ParseOptions reads export options from query parameters:
+//
+//	columns      comma separated column names, in output order (default: all)
+//	labels       comma separated header labels, one per column
+//	delimiter    comma (default), semicolon or tab
+//	date_format  rfc3339nano (default), rfc3339, date, datetime, unix or a Go layout
+//	tz           IANA time zone name, UTC by default
+//	filename     attachment name; the extension is added when missing
+//	comments     true to include the comments of every task
+func ParseOptions(q url.Values, format Format, now time.Time) (Options, error) {
+	opts := Options{
+		Columns:    TaskColumns,
+		Delimiter:  ',',
+		DateFormat: time.RFC3339Nano,
+		Location:   time.UTC,
+	}
+
+	if columns := q.Get("columns"); columns != "" {
+		opts.Columns = nil
+		for _, name := range strings.Split(columns, ",") {
+			column, ok := TaskColumn(strings.TrimSpace(name))
+			if !ok {
+				return Options{}, fmt.Errorf("column %q is not allowed", name)
+			}
+			opts.Columns = append(opts.Columns, column)
+		}
+	}
+
+	opts.Labels = make([]string, 0, len(opts.Columns))
+	for _, c := range opts.Columns {
+		opts.Labels = append(opts.Labels, c.Label)
+	}
+	if labels := q.Get("labels"); labels != "" {
+		split := strings.Split(labels, ",")
+		if len(split) != len(opts.Columns) {
+			return Options{}, errors.New("labels count does not match columns count")
+		}
+		opts.Labels = split
+	}
+
+	switch q.Get("delimiter") {
+	case "", "comma":
+	case "semicolon":
+		opts.Delimiter = ';'
+	case "tab":
+		opts.Delimiter = '\t'
+	default:
+		return Options{}, errors.New("delimiter is not valid")
+	}
+
+	if dateFormat := q.Get("date_format"); dateFormat != "" {
+		if layout, ok := dateFormatLayouts[dateFormat]; ok {
+			opts.DateFormat = layout
+		} else {
+			opts.DateFormat = dateFormat
+		}
+	}
+
+	if tz := q.Get("tz"); tz != "" {
+		location, err := time.LoadLocation(tz)
+		if err != nil {
+			return Options{}, fmt.Errorf("time zone is not valid: %v", err)
+		}
+		opts.Location = location
+	}
+
+	if comments := q.Get("comments"); comments != "" {
+		var err error
+		opts.Comments, err = strconv.ParseBool(comments)
+		if err != nil {
+			return Options{}, errors.New("comments is not valid")
+		}
+	}
+
+	opts.Filename = Filename(q.Get("filename"), format.Extension, now)
+
+	return opts, nil
+}
+
+// FormatTime renders t in the requested zone and format. Zero times, such as
+// a task without a due date, are left empty.
+func (o Options) FormatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	if o.DateFormat == DateFormatUnix {
+		return strconv.FormatInt(t.Unix(), 10)
+	}
+	return t.In(o.Location).Format(o.DateFormat)
+}
+
+func (o Options) Header() []string {
+	if o.Comments {
+		return append(o.Labels[:len(o.Labels):len(o.Labels)], CommentsLabel)
+	}
+	return o.Labels
+}
+
+// Values returns the typed values of the selected columns of t, followed by
+// the comments when they are included.
+func (o Options) Values(t db.TaskModel, comments []db.CommentModel) []interface{} {
+	values := make([]interface{}, 0, len(o.Columns)+1)
+	for _, c := range o.Columns {
+		values = append(values, c.Value(t))
+	}
+	if o.Comments {
+		contents := make([]string, 0, len(comments))
+		for _, c := range comments {
+			contents = append(contents, c.Content)
+		}
+		values = append(values, strings.Join(contents, "\n"))
+	}
+	return values
+}
+
+// Row is Values rendered as text, as CSV and Markdown need it.
+func (o Options) Row(t db.TaskModel, comments []db.CommentModel) []string {
+	values := o.Values(t, comments)
+	row := make([]string, 0, len(values))
+	for _, v := range values {
+		row = append(row, o.Format(v))
+	}
+	return row
+}
+
+func (o Options) Format(v interface{}) string {
+	switch v := v.(type) {
+	case time.Time:
+		return o.FormatTime(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Filename sanitizes a client supplied file name for Content-Disposition and
+// falls back to tasks-<timestamp> when none is given.
+func Filename(requested, ext string, now time.Time) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		default:
+			return '_'
+		}
+	}, strings.TrimSpace(requested))
+	name = strings.Trim(name, ".")
+
+	if name == "" {
+		name = "tasks-" + now.UTC().Format("20060102-150405")
+	}
+	if !strings.HasSuffix(strings.ToLower(name), "."+ext) {
+		name += "." + ext
+	}
+
+	return name
+}
@@ -0,0 +1,122 @@
+package export
+
+import (
+	"io"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gitlab.com/vitbog/titov-rest/internal/db"
+)
+
+// Writer renders tasks in one export format. Flush is called periodically so
+// formats that can stream push rows to the client early; Close finishes the
+// document.
+type Writer interface {
+	WriteHeader() error
+	Write(t db.TaskModel, comments []db.CommentModel) error
+	Flush() error
+	Close() error
+}
+
+type Format struct {
+	Name        string
+	ContentType string
+	Extension   string
+	// MediaTypes are the Accept header values that select the format.
+	MediaTypes []string
+	New        func(w io.Writer, opts Options) Writer
+}
+
+var (
+	FormatCSV = Format{
+		Name:        "csv",
+		ContentType: CSVContentType,
+		Extension:   "csv",
+		MediaTypes:  []string{"text/csv"},
+		New:         func(w io.Writer, opts Options) Writer { return NewCSV(w, opts) },
+	}
+	FormatNDJSON = Format{
+		Name:        "ndjson",
+		ContentType: NDJSONContentType,
+		Extension:   "ndjson",
+		MediaTypes:  []string{"application/x-ndjson", "application/jsonl", "application/json"},
+		New:         func(w io.Writer, opts Options) Writer { return NewNDJSON(w, opts) },
+	}
+	FormatXLSX = Format{
+		Name:        "xlsx",
+		ContentType: XLSXContentType,
+		Extension:   "xlsx",
+		MediaTypes:  []string{XLSXContentType},
+		New:         func(w io.Writer, opts Options) Writer { return NewXLSX(w, opts) },
+	}
+	FormatMarkdown = Format{
+		Name:        "markdown",
+		ContentType: MarkdownContentType,
+		Extension:   "md",
+		MediaTypes:  []string{"text/markdown"},
+		New:         func(w io.Writer, opts Options) Writer { return NewMarkdown(w, opts) },
+	}
+)
+
+// Formats lists the supported formats; the first one is the default.
+var Formats = []Format{FormatCSV, FormatNDJSON, FormatXLSX, FormatMarkdown}
+
+func FormatByName(name string) (Format, bool) {
+	for _, f := range Formats {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Format{}, false
+}
+
+// Negotiate picks the export format. An explicit format name wins over the
+// Accept header; a missing or wildcard Accept header selects the default.
+func Negotiate(name, accept string) (Format, bool) {
+	if name != "" {
+		return FormatByName(name)
+	}
+	if strings.TrimSpace(accept) == "" {
+		return Formats[0], true
+	}
+
+	type candidate struct {
+		mediaType string
+		q         float64
+	}
+	candidates := []candidate{}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if qStr, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(qStr, 64)
+			if err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{mediaType: mediaType, q: q})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+
+	for _, c := range candidates {
+		if c.mediaType == "*/*" || c.mediaType == "text/*" {
+			return Formats[0], true
+		}
+		for _, f := range Formats {
+			for _, mediaType := range f.MediaTypes {
+				if c.mediaType == mediaType {
+					return f, true
+				}
+			}
+		}
+	}
+
+	return Format{}, false
+}
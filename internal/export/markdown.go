@@ -0,0 +1,64 @@
+package export
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"gitlab.com/vitbog/titov-rest/internal/db"
+)
+
+const MarkdownContentType = "text/markdown; charset=utf-8"
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"|", `\|`,
+	"\r\n", "<br>",
+	"\n", "<br>",
+	"\r", "<br>",
+)
+
+// Markdown writes a GitHub flavored Markdown table.
+type Markdown struct {
+	w    *bufio.Writer
+	opts Options
+}
+
+func NewMarkdown(w io.Writer, opts Options) *Markdown {
+	return &Markdown{w: bufio.NewWriter(w), opts: opts}
+}
+
+func (m *Markdown) WriteHeader() error {
+	header := m.opts.Header()
+	err := m.writeRow(header)
+	if err != nil {
+		return err
+	}
+
+	separator := make([]string, len(header))
+	for i := range separator {
+		separator[i] = "---"
+	}
+	return m.writeRow(separator)
+}
+
+func (m *Markdown) Write(t db.TaskModel, comments []db.CommentModel) error {
+	return m.writeRow(m.opts.Row(t, comments))
+}
+
+func (m *Markdown) writeRow(cells []string) error {
+	escaped := make([]string, 0, len(cells))
+	for _, c := range cells {
+		escaped = append(escaped, markdownEscaper.Replace(c))
+	}
+	_, err := m.w.WriteString("| " + strings.Join(escaped, " | ") + " |\n")
+	return err
+}
+
+func (m *Markdown) Flush() error {
+	return m.w.Flush()
+}
+
+func (m *Markdown) Close() error {
+	return m.Flush()
+}
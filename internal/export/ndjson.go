@@ -0,0 +1,54 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+
+	"gitlab.com/vitbog/titov-rest/internal/db"
+)
+
+const NDJSONContentType = "application/x-ndjson"
+
+// NDJSON writes one JSON object per task. Objects always carry every task
+// field as the JSON API does; column, label and date options only shape the
+// tabular formats.
+type NDJSON struct {
+	w    *bufio.Writer
+	enc  *json.Encoder
+	opts Options
+}
+
+type ndjsonTask struct {
+	db.TaskModel
+	Comments []db.CommentModel `json:"comments,omitempty"`
+}
+
+func NewNDJSON(w io.Writer, opts Options) *NDJSON {
+	writer := bufio.NewWriter(w)
+
+	return &NDJSON{w: writer, enc: json.NewEncoder(writer), opts: opts}
+}
+
+func (n *NDJSON) WriteHeader() error {
+	return nil
+}
+
+func (n *NDJSON) Write(t db.TaskModel, comments []db.CommentModel) error {
+	task := ndjsonTask{TaskModel: t}
+	if n.opts.Comments {
+		task.Comments = comments
+		if task.Comments == nil {
+			task.Comments = []db.CommentModel{}
+		}
+	}
+	return n.enc.Encode(task)
+}
+
+func (n *NDJSON) Flush() error {
+	return n.w.Flush()
+}
+
+func (n *NDJSON) Close() error {
+	return n.Flush()
+}
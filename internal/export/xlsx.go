@@ -0,0 +1,122 @@
+package export
+
+import (
+	"io"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+	"gitlab.com/vitbog/titov-rest/internal/db"
+)
+
+const XLSXContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+const xlsxSheet = "Tasks"
+
+// XLSX writes a single sheet workbook with a bold header row, numeric id
+// cells and real date cells. The workbook is a zip archive, so nothing
+// reaches the client before Close; excelize spills large sheets to disk.
+type XLSX struct {
+	w    io.Writer
+	opts Options
+
+	file       *excelize.File
+	sheet      *excelize.StreamWriter
+	headStyle  int
+	dateStyle  int
+	row        int
+	err        error
+	dateFormat string
+}
+
+func NewXLSX(w io.Writer, opts Options) *XLSX {
+	x := &XLSX{w: w, opts: opts, file: excelize.NewFile()}
+
+	x.dateFormat = "yyyy-mm-dd hh:mm:ss"
+	if opts.DateFormat == time.DateOnly {
+		x.dateFormat = "yyyy-mm-dd"
+	}
+
+	x.err = x.file.SetSheetName("Sheet1", xlsxSheet)
+	if x.err != nil {
+		return x
+	}
+	x.sheet, x.err = x.file.NewStreamWriter(xlsxSheet)
+	if x.err != nil {
+		return x
+	}
+	x.headStyle, x.err = x.file.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if x.err != nil {
+		return x
+	}
+	x.dateStyle, x.err = x.file.NewStyle(&excelize.Style{CustomNumFmt: &x.dateFormat})
+
+	return x
+}
+
+func (x *XLSX) WriteHeader() error {
+	if x.err != nil {
+		return x.err
+	}
+
+	header := x.opts.Header()
+	cells := make([]interface{}, 0, len(header))
+	for _, label := range header {
+		cells = append(cells, excelize.Cell{StyleID: x.headStyle, Value: label})
+	}
+
+	return x.setRow(cells)
+}
+
+func (x *XLSX) Write(t db.TaskModel, comments []db.CommentModel) error {
+	if x.err != nil {
+		return x.err
+	}
+
+	values := x.opts.Values(t, comments)
+	cells := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		switch v := v.(type) {
+		case time.Time:
+			if v.IsZero() {
+				cells = append(cells, nil)
+				continue
+			}
+			// Excel dates have no zone: keep the wall clock of the requested
+			// zone by re-basing it onto UTC.
+			local := v.In(x.opts.Location)
+			wall := time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), local.Minute(), local.Second(), local.Nanosecond(), time.UTC)
+			cells = append(cells, excelize.Cell{StyleID: x.dateStyle, Value: wall})
+		default:
+			cells = append(cells, v)
+		}
+	}
+
+	return x.setRow(cells)
+}
+
+func (x *XLSX) setRow(cells []interface{}) error {
+	x.row++
+	cell, err := excelize.CoordinatesToCellName(1, x.row)
+	if err != nil {
+		return err
+	}
+	return x.sheet.SetRow(cell, cells)
+}
+
+func (x *XLSX) Flush() error {
+	return x.err
+}
+
+func (x *XLSX) Close() error {
+	defer x.file.Close()
+	if x.err != nil {
+		return x.err
+	}
+
+	err := x.sheet.Flush()
+	if err != nil {
+		return err
+	}
+
+	return x.file.Write(x.w)
+}
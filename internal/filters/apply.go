@@ -0,0 +1,171 @@
+package filters
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timestampLayouts are the forms of timestamp Postgres accepts that clients
+// actually send. Like a timestamp without time zone column, any zone in the
+// value is ignored.
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// Apply is Filter for rows held in memory: it filters, sorts and pages rows
+// the way the query built by Filter would. column returns the value of a
+// column for a row as int64, string, time.Time or nil for NULL. Filter
+// values are converted to the type of the column like Postgres converts
+// query parameters, and a value that does not convert is an error.
+func Apply[R any](f Filtering, rows []R, allowedColumns []string, choosenColumns []string, column func(row R, name string) interface{}) ([]R, error) {
+	if len(choosenColumns) == 0 {
+		return rows, nil
+	}
+
+	doSort, err := f.check(allowedColumns, choosenColumns)
+	if err != nil {
+		return nil, err
+	}
+	if doSort && len(f.SortColumn) == 0 {
+		f.SortColumn = choosenColumns[0]
+	}
+
+	filtered := make([]R, 0, len(rows))
+	for _, row := range rows {
+		matches := true
+		for _, filter := range f.Filters {
+			equal, err := equals(column(row, filter.FieldName), filter.Equals)
+			if err != nil {
+				return nil, fmt.Errorf("filter %s: %w", filter.FieldName, err)
+			}
+			if !equal {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			filtered = append(filtered, row)
+		}
+	}
+
+	if doSort {
+		desc := f.SortType == SortTypeDesc
+		slices.SortStableFunc(filtered, func(a, b R) int {
+			c := compare(column(a, f.SortColumn), column(b, f.SortColumn))
+			if desc {
+				return -c
+			}
+			return c
+		})
+	}
+
+	if f.Offset > 0 {
+		filtered = filtered[min(f.Offset, len(filtered)):]
+	}
+	if f.Limit > 0 {
+		filtered = filtered[:min(f.Limit, len(filtered))]
+	}
+
+	return filtered, nil
+}
+
+// equals compares a column value with a filter value. NULL equals nothing,
+// not even NULL.
+func equals(value interface{}, filterValue interface{}) (bool, error) {
+	if value == nil || filterValue == nil {
+		return false, nil
+	}
+
+	switch v := value.(type) {
+	case int64:
+		n, err := toInt64(filterValue)
+		if err != nil {
+			return false, err
+		}
+		return v == n, nil
+	case string:
+		return v == toText(filterValue), nil
+	case time.Time:
+		t, err := toTimestamp(filterValue)
+		if err != nil {
+			return false, err
+		}
+		return v.Equal(t), nil
+	}
+
+	panic(fmt.Sprintf("filters: unsupported column type %T", value))
+}
+
+// compare orders column values like Postgres: NULLs sort after every value.
+func compare(a, b interface{}) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+
+	switch a := a.(type) {
+	case int64:
+		return cmp.Compare(a, b.(int64))
+	case string:
+		return strings.Compare(a, b.(string))
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	}
+
+	panic(fmt.Sprintf("filters: unsupported column type %T", a))
+}
+
+func toInt64(v interface{}) (int64, error) {
+	switch v := v.(type) {
+	case int:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, fmt.Errorf("%v is not an integer", v)
+		}
+		return int64(v), nil
+	case json.Number:
+		return v.Int64()
+	case string:
+		return strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+	}
+	return 0, fmt.Errorf("%v is not an integer", v)
+}
+
+func toText(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
+
+func toTimestamp(v interface{}) (time.Time, error) {
+	s, ok := v.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("%v is not a timestamp", v)
+	}
+
+	for _, layout := range timestampLayouts {
+		t, err := time.Parse(layout, strings.TrimSpace(s))
+		if err == nil {
+			return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not a timestamp", s)
+}
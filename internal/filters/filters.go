@@ -34,26 +34,12 @@ func (f Filtering) Filter(query string, argsInQuery int, allowedColumns []string
 		return query, nil, nil
 	}
 
-	for _, column := range choosenColumns {
-		if !slices.Contains(allowedColumns, column) {
-			return "", nil, fmt.Errorf("choosen column not allowed")
-		}
+	doSort, err := f.check(allowedColumns, choosenColumns)
+	if err != nil {
+		return "", nil, err
 	}
-	for _, filter := range f.Filters {
-		if !slices.Contains(allowedColumns, filter.FieldName) {
-			return "", nil, fmt.Errorf("choosen field in filters not allowed")
-		}
-	}
-
-	doSort := IsValidSortType(f.SortType)
-
-	if doSort {
-		if len(choosenColumns) == 0 {
-			return "", nil, errors.New("no columns specified")
-		}
-		if len(f.SortColumn) == 0 {
-			f.SortColumn = choosenColumns[0]
-		}
+	if doSort && len(f.SortColumn) == 0 {
+		f.SortColumn = choosenColumns[0]
 	}
 
 	tableName := "unfiltered"
@@ -99,6 +85,34 @@ func (f Filtering) Filter(query string, argsInQuery int, allowedColumns []string
 
 }
 
+// check rejects columns, filters and sort columns outside allowedColumns and
+// reports whether the result is sorted.
+func (f Filtering) check(allowedColumns []string, choosenColumns []string) (bool, error) {
+	for _, column := range choosenColumns {
+		if !slices.Contains(allowedColumns, column) {
+			return false, fmt.Errorf("choosen column not allowed")
+		}
+	}
+	for _, filter := range f.Filters {
+		if !slices.Contains(allowedColumns, filter.FieldName) {
+			return false, fmt.Errorf("choosen field in filters not allowed")
+		}
+	}
+
+	doSort := IsValidSortType(f.SortType)
+
+	if doSort {
+		if len(choosenColumns) == 0 {
+			return false, errors.New("no columns specified")
+		}
+		if len(f.SortColumn) != 0 && !slices.Contains(allowedColumns, f.SortColumn) {
+			return false, fmt.Errorf("choosen sort column not allowed")
+		}
+	}
+
+	return doSort, nil
+}
+
 func ConvertInterfaceToString(interf interface{}) (string, error) {
 	if val, ok := interf.(string); ok {
 		return val, nil
@@ -0,0 +1,68 @@
+package filters
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Reserved query parameters; every other parameter is an equality filter.
+const (
+	QuerySort   = "sort"
+	QueryLimit  = "limit"
+	QueryOffset = "offset"
+)
+
+// FromQuery builds a Filtering from query string parameters, e.g.
+//
+//	?status=pending&id_user=3&sort=-due_date&limit=20&offset=40
+//
+// sort takes a column name, prefixed with "-" for descending order. Column
+// names are not checked here; Filter rejects the ones a query does not allow.
+func FromQuery(query url.Values) (Filtering, error) {
+	var f Filtering
+
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	// Sorted so the same query always builds the same SQL.
+	slices.Sort(names)
+
+	for _, name := range names {
+		values := query[name]
+		if len(values) != 1 {
+			return Filtering{}, fmt.Errorf("query parameter %s must be given once", name)
+		}
+		value := values[0]
+
+		switch name {
+		case QuerySort:
+			f.SortType = SortTypeAsc
+			column, desc := strings.CutPrefix(value, "-")
+			if desc {
+				f.SortType = SortTypeDesc
+			}
+			if column == "" {
+				return Filtering{}, fmt.Errorf("query parameter %s has no column", name)
+			}
+			f.SortColumn = column
+		case QueryLimit, QueryOffset:
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return Filtering{}, fmt.Errorf("query parameter %s must be a non-negative integer", name)
+			}
+			if name == QueryLimit {
+				f.Limit = n
+			} else {
+				f.Offset = n
+			}
+		default:
+			f.Filters = append(f.Filters, Filter{FieldName: name, Equals: value})
+		}
+	}
+
+	return f, nil
+}
@@ -0,0 +1,5 @@
+// Package graph holds the GraphQL schema and the executable schema gqlgen
+// generates from it. The resolvers live in the server package.
+package graph
+
+//go:generate go run github.com/99designs/gqlgen generate --config gqlgen.yml
@@ -0,0 +1,93 @@
+// Code generated by github.com/99designs/gqlgen, DO NOT EDIT.
+
+package graph
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+type FilterInput struct {
+	Field  string `json:"field"`
+	Equals string `json:"equals"`
+}
+
+// Mirrors the filtering body of the REST list endpoints.
+type FilteringInput struct {
+	SortType   *SortType      `json:"sortType,omitempty"`
+	SortColumn *string        `json:"sortColumn,omitempty"`
+	Limit      *int           `json:"limit,omitempty"`
+	Offset     *int           `json:"offset,omitempty"`
+	Filters    []*FilterInput `json:"filters,omitempty"`
+}
+
+type Mutation struct {
+}
+
+type Query struct {
+}
+
+type TaskInput struct {
+	Title       string    `json:"title"`
+	Description *string   `json:"description,omitempty"`
+	Status      string    `json:"status"`
+	DueDate     time.Time `json:"dueDate"`
+}
+
+type SortType string
+
+const (
+	SortTypeAsc  SortType = "ASC"
+	SortTypeDesc SortType = "DESC"
+)
+
+var AllSortType = []SortType{
+	SortTypeAsc,
+	SortTypeDesc,
+}
+
+func (e SortType) IsValid() bool {
+	switch e {
+	case SortTypeAsc, SortTypeDesc:
+		return true
+	}
+	return false
+}
+
+func (e SortType) String() string {
+	return string(e)
+}
+
+func (e *SortType) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = SortType(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid SortType", str)
+	}
+	return nil
+}
+
+func (e SortType) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *SortType) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e SortType) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
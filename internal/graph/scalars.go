@@ -0,0 +1,37 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// MarshalID writes database ids as the strings GraphQL IDs are.
+func MarshalID(id int64) graphql.Marshaler {
+	return graphql.WriterFunc(func(w io.Writer) {
+		io.WriteString(w, strconv.Quote(strconv.FormatInt(id, 10)))
+	})
+}
+
+// UnmarshalID accepts an id as a string or a number.
+func UnmarshalID(v any) (int64, error) {
+	switch v := v.(type) {
+	case string:
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not an id", v)
+		}
+		return id, nil
+	case json.Number:
+		return v.Int64()
+	case int:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	default:
+		return 0, fmt.Errorf("%T is not an id", v)
+	}
+}
@@ -0,0 +1,156 @@
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const ContentType = "text/calendar; charset=utf-8"
+
+const (
+	ComponentTodo  = "VTODO"
+	ComponentEvent = "VEVENT"
+)
+
+const (
+	StatusNeedsAction = "NEEDS-ACTION"
+	StatusInProcess   = "IN-PROCESS"
+	StatusCompleted   = "COMPLETED"
+	StatusCancelled   = "CANCELLED"
+)
+
+const (
+	dateTimeLayout = "20060102T150405Z"
+	maxLineOctets  = 75
+)
+
+var textEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\;`,
+	",", `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+	"\r", `\n`,
+)
+
+// Item is one VTODO or VEVENT of the calendar. A VTODO is due at Due; a
+// VEVENT starts at Due and, as RFC 5545 allows, has no end. Status holds a
+// VTODO status and is left out of VEVENTs.
+type Item struct {
+	Component    string
+	UID          string
+	Summary      string
+	Description  string
+	Status       string
+	Categories   []string
+	Created      time.Time
+	LastModified time.Time
+	Due          time.Time
+}
+
+type Calendar struct {
+	ProdId string
+	Name   string
+	Items  []Item
+}
+
+// Write renders the calendar as RFC 5545 text: CRLF line endings, escaped
+// text values and lines folded at 75 octets.
+func (c Calendar) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	lw := &lineWriter{w: bw}
+
+	lw.line("BEGIN", "VCALENDAR")
+	lw.line("VERSION", "2.0")
+	lw.line("PRODID", c.ProdId)
+	lw.line("CALSCALE", "GREGORIAN")
+	lw.line("METHOD", "PUBLISH")
+	if c.Name != "" {
+		lw.line("X-WR-CALNAME", Escape(c.Name))
+	}
+
+	for _, item := range c.Items {
+		stamp := item.LastModified
+		if stamp.IsZero() {
+			stamp = time.Now()
+		}
+
+		lw.line("BEGIN", item.Component)
+		lw.line("UID", item.UID)
+		lw.line("DTSTAMP", FormatTime(stamp))
+		if !item.Created.IsZero() {
+			lw.line("CREATED", FormatTime(item.Created))
+		}
+		if !item.LastModified.IsZero() {
+			lw.line("LAST-MODIFIED", FormatTime(item.LastModified))
+		}
+		lw.line("SUMMARY", Escape(item.Summary))
+		if item.Description != "" {
+			lw.line("DESCRIPTION", Escape(item.Description))
+		}
+		if item.Component == ComponentEvent {
+			lw.line("DTSTART", FormatTime(item.Due))
+		} else {
+			lw.line("DUE", FormatTime(item.Due))
+		}
+		if item.Status != "" && item.Component == ComponentTodo {
+			lw.line("STATUS", item.Status)
+		}
+		if len(item.Categories) > 0 {
+			escaped := make([]string, 0, len(item.Categories))
+			for _, category := range item.Categories {
+				escaped = append(escaped, Escape(category))
+			}
+			lw.line("CATEGORIES", strings.Join(escaped, ","))
+		}
+		lw.line("END", item.Component)
+	}
+
+	lw.line("END", "VCALENDAR")
+
+	if lw.err != nil {
+		return lw.err
+	}
+	return bw.Flush()
+}
+
+func Escape(text string) string {
+	return textEscaper.Replace(text)
+}
+
+func FormatTime(t time.Time) string {
+	return t.UTC().Format(dateTimeLayout)
+}
+
+type lineWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+// line writes "name:value", folding it into continuation lines that start
+// with a space, without splitting multi-byte characters.
+func (lw *lineWriter) line(name, value string) {
+	if lw.err != nil {
+		return
+	}
+
+	rest := name + ":" + value
+	limit := maxLineOctets
+	for len(rest) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(rest[cut]) {
+			cut--
+		}
+		_, lw.err = lw.w.WriteString(rest[:cut] + "\r\n ")
+		if lw.err != nil {
+			return
+		}
+		rest = rest[cut:]
+		// Continuation lines lose one octet to the leading space.
+		limit = maxLineOctets - 1
+	}
+	_, lw.err = lw.w.WriteString(rest + "\r\n")
+}
@@ -0,0 +1,145 @@
+// Package metrics collects the Prometheus metrics of the service: HTTP
+// requests by route, storage calls, connection pools, logins and the number
+// of tasks by status.
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gitlab.com/vitbog/titov-rest/internal/db"
+)
+
+const namespace = "titov"
+
+const (
+	resultSuccess = "success"
+	resultFailure = "failure"
+	resultError   = "error"
+)
+
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+	dbDuration   *prometheus.HistogramVec
+	authAttempts *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests answered, by method, route template and status.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time to answer HTTP requests, by method and route template.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		dbDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "db_query_duration_seconds",
+			Help:      "Time spent in storage calls, by method and result.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "result"}),
+		authAttempts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "auth_attempts_total",
+			Help:      "Logins by result: success, failure for wrong credentials, or error.",
+		}, []string{"result"}),
+	}
+
+	m.Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.dbDuration,
+		m.authAttempts,
+	)
+
+	return m
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.Registry, promhttp.HandlerOpts{Registry: m.Registry})
+}
+
+// RegisterDBStats adds the statistics of the connection pool named name.
+func (m *Metrics) RegisterDBStats(name string, pool *sql.DB) error {
+	return m.Registry.Register(collectors.NewDBStatsCollector(pool, name))
+}
+
+// RegisterTasks adds the tasks gauge, counted from tasks on every scrape.
+func (m *Metrics) RegisterTasks(tasks db.TaskRepository) error {
+	return m.Registry.Register(&tasksCollector{
+		tasks: tasks,
+		desc: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "tasks"),
+			"Tasks stored, by status.", []string{"status"}, nil),
+	})
+}
+
+func (m *Metrics) ObserveHTTP(method, route string, status int, duration time.Duration) {
+	m.httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	m.httpDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+// AuthAttempt counts a login: err is nil for a success and
+// db.ErrInvalidCredentials for wrong credentials.
+func (m *Metrics) AuthAttempt(err error) {
+	m.authAttempts.WithLabelValues(authResult(err)).Inc()
+}
+
+func authResult(err error) string {
+	switch {
+	case err == nil:
+		return resultSuccess
+	case errors.Is(err, db.ErrInvalidCredentials):
+		return resultFailure
+	}
+	return resultError
+}
+
+func (m *Metrics) observeDb(method string, start time.Time, err error) {
+	result := resultSuccess
+	if err != nil {
+		result = resultError
+	}
+	m.dbDuration.WithLabelValues(method, result).Observe(time.Since(start).Seconds())
+}
+
+type tasksCollector struct {
+	tasks db.TaskRepository
+	desc  *prometheus.Desc
+}
+
+func (c *tasksCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *tasksCollector) Collect(ch chan<- prometheus.Metric) {
+	counts, err := c.tasks.TasksCountByStatus(context.Background())
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+
+	// Every status is reported, so a status running out of tasks drops to
+	// zero instead of disappearing.
+	for _, status := range []db.TaskStatus{db.TaskStatusFrozen, db.TaskStatusPending, db.TaskStatusInProgress, db.TaskStatusCompleted} {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(counts[status]), string(status))
+	}
+}
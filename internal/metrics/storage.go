@@ -0,0 +1,209 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"gitlab.com/vitbog/titov-rest/internal/db"
+	"gitlab.com/vitbog/titov-rest/internal/filters"
+)
+
+// Storage times every call of the storage Next, see db_query_duration_seconds.
+type Storage struct {
+	Next    db.Storage
+	metrics *Metrics
+}
+
+var _ db.Storage = (*Storage)(nil)
+
+func (m *Metrics) Storage(next db.Storage) *Storage {
+	return &Storage{Next: next, metrics: m}
+}
+
+// Unwrap returns the storage underneath.
+func (s *Storage) Unwrap() db.Storage {
+	return s.Next
+}
+
+func (s *Storage) observe(method string, start time.Time, err *error) {
+	s.metrics.observeDb(method, start, *err)
+}
+
+func (s *Storage) Ping(ctx context.Context) (err error) {
+	defer s.observe("Ping", time.Now(), &err)
+	return s.Next.Ping(ctx)
+}
+
+func (s *Storage) Close() error {
+	return s.Next.Close()
+}
+
+func (s *Storage) TasksCreate(ctx context.Context, userLogin, taskTitle, taskDescription, taskStatus string, DueDate time.Time) (id int64, err error) {
+	defer s.observe("TasksCreate", time.Now(), &err)
+	return s.Next.TasksCreate(ctx, userLogin, taskTitle, taskDescription, taskStatus, DueDate)
+}
+
+func (s *Storage) TasksCreateMany(ctx context.Context, userLogin string, tasks []db.TaskModel) (ids []int64, err error) {
+	defer s.observe("TasksCreateMany", time.Now(), &err)
+	return s.Next.TasksCreateMany(ctx, userLogin, tasks)
+}
+
+func (s *Storage) Tasks(ctx context.Context, filt filters.Filtering) (tasks []db.TaskModel, err error) {
+	defer s.observe("Tasks", time.Now(), &err)
+	return s.Next.Tasks(ctx, filt)
+}
+
+func (s *Storage) Task(ctx context.Context, taskId int64) (task db.TaskModel, err error) {
+	defer s.observe("Task", time.Now(), &err)
+	return s.Next.Task(ctx, taskId)
+}
+
+func (s *Storage) TasksByIds(ctx context.Context, taskIds []int64) (tasks map[int64]db.TaskModel, err error) {
+	defer s.observe("TasksByIds", time.Now(), &err)
+	return s.Next.TasksByIds(ctx, taskIds)
+}
+
+func (s *Storage) TasksEach(ctx context.Context, filt filters.Filtering, fn func(db.TaskModel) error) (err error) {
+	defer s.observe("TasksEach", time.Now(), &err)
+	return s.Next.TasksEach(ctx, filt, fn)
+}
+
+func (s *Storage) TasksUpdate(ctx context.Context, taskId int64, taskTitle, taskDescription, taskStatus string, DueDate time.Time) (err error) {
+	defer s.observe("TasksUpdate", time.Now(), &err)
+	return s.Next.TasksUpdate(ctx, taskId, taskTitle, taskDescription, taskStatus, DueDate)
+}
+
+func (s *Storage) TasksDelete(ctx context.Context, ids []int64) (err error) {
+	defer s.observe("TasksDelete", time.Now(), &err)
+	return s.Next.TasksDelete(ctx, ids)
+}
+
+func (s *Storage) TasksBatch(ctx context.Context, ids []int64, filt *filters.Filtering, action db.TaskBatchAction, maxItems int) (result db.TaskBatchResult, err error) {
+	defer s.observe("TasksBatch", time.Now(), &err)
+	return s.Next.TasksBatch(ctx, ids, filt, action, maxItems)
+}
+
+func (s *Storage) TasksCountByStatus(ctx context.Context) (counts map[db.TaskStatus]int64, err error) {
+	defer s.observe("TasksCountByStatus", time.Now(), &err)
+	return s.Next.TasksCountByStatus(ctx)
+}
+
+func (s *Storage) Comments(ctx context.Context, taskId int64, filt filters.Filtering) (comments []db.CommentModel, err error) {
+	defer s.observe("Comments", time.Now(), &err)
+	return s.Next.Comments(ctx, taskId, filt)
+}
+
+func (s *Storage) Comment(ctx context.Context, commentId int64) (comment db.CommentModel, err error) {
+	defer s.observe("Comment", time.Now(), &err)
+	return s.Next.Comment(ctx, commentId)
+}
+
+func (s *Storage) CommentCreate(ctx context.Context, taskId int64, userLogin, content string) (id int64, err error) {
+	defer s.observe("CommentCreate", time.Now(), &err)
+	return s.Next.CommentCreate(ctx, taskId, userLogin, content)
+}
+
+func (s *Storage) CommentsByTasks(ctx context.Context, taskIds []int64) (comments map[int64][]db.CommentModel, err error) {
+	defer s.observe("CommentsByTasks", time.Now(), &err)
+	return s.Next.CommentsByTasks(ctx, taskIds)
+}
+
+func (s *Storage) Auth(ctx context.Context, login, password string) (result string, err error) {
+	defer s.observe("Auth", time.Now(), &err)
+	return s.Next.Auth(ctx, login, password)
+}
+
+func (s *Storage) Register(ctx context.Context, login, password, roleName, fname, lname string) (id int64, err error) {
+	defer s.observe("Register", time.Now(), &err)
+	return s.Next.Register(ctx, login, password, roleName, fname, lname)
+}
+
+func (s *Storage) UsersByIds(ctx context.Context, userIds []int64) (users map[int64]db.UserModel, err error) {
+	defer s.observe("UsersByIds", time.Now(), &err)
+	return s.Next.UsersByIds(ctx, userIds)
+}
+
+func (s *Storage) UserByLogin(ctx context.Context, login string) (user db.UserModel, err error) {
+	defer s.observe("UserByLogin", time.Now(), &err)
+	return s.Next.UserByLogin(ctx, login)
+}
+
+func (s *Storage) FeedTokenSet(ctx context.Context, login, feedToken string) (err error) {
+	defer s.observe("FeedTokenSet", time.Now(), &err)
+	return s.Next.FeedTokenSet(ctx, login, feedToken)
+}
+
+func (s *Storage) FeedTokenUser(ctx context.Context, feedToken string) (id int64, result string, err error) {
+	defer s.observe("FeedTokenUser", time.Now(), &err)
+	return s.Next.FeedTokenUser(ctx, feedToken)
+}
+
+func (s *Storage) Events(ctx context.Context, afterId int64, limit int) (events []db.EventModel, err error) {
+	defer s.observe("Events", time.Now(), &err)
+	return s.Next.Events(ctx, afterId, limit)
+}
+
+func (s *Storage) EventsLastId(ctx context.Context) (id int64, err error) {
+	defer s.observe("EventsLastId", time.Now(), &err)
+	return s.Next.EventsLastId(ctx)
+}
+
+func (s *Storage) EventsCleanup(ctx context.Context, before time.Time) (err error) {
+	defer s.observe("EventsCleanup", time.Now(), &err)
+	return s.Next.EventsCleanup(ctx, before)
+}
+
+func (s *Storage) SyncNow(ctx context.Context) (now time.Time, err error) {
+	defer s.observe("SyncNow", time.Now(), &err)
+	return s.Next.SyncNow(ctx)
+}
+
+func (s *Storage) SyncTasks(ctx context.Context, since, until time.Time) (tasks []db.TaskModel, err error) {
+	defer s.observe("SyncTasks", time.Now(), &err)
+	return s.Next.SyncTasks(ctx, since, until)
+}
+
+func (s *Storage) SyncComments(ctx context.Context, since, until time.Time) (comments []db.CommentModel, err error) {
+	defer s.observe("SyncComments", time.Now(), &err)
+	return s.Next.SyncComments(ctx, since, until)
+}
+
+func (s *Storage) SyncTombstones(ctx context.Context, since, until time.Time) (tombstones []db.TombstoneModel, err error) {
+	defer s.observe("SyncTombstones", time.Now(), &err)
+	return s.Next.SyncTombstones(ctx, since, until)
+}
+
+func (s *Storage) SyncTombstonesCleanup(ctx context.Context, before time.Time) (err error) {
+	defer s.observe("SyncTombstonesCleanup", time.Now(), &err)
+	return s.Next.SyncTombstonesCleanup(ctx, before)
+}
+
+func (s *Storage) SyncTaskUpdate(ctx context.Context, taskId int64, taskTitle, taskDescription, taskStatus string, DueDate time.Time, baseUpdatedAt *time.Time) (result string, err error) {
+	defer s.observe("SyncTaskUpdate", time.Now(), &err)
+	return s.Next.SyncTaskUpdate(ctx, taskId, taskTitle, taskDescription, taskStatus, DueDate, baseUpdatedAt)
+}
+
+func (s *Storage) SyncTaskDelete(ctx context.Context, taskId int64, baseUpdatedAt *time.Time) (result string, err error) {
+	defer s.observe("SyncTaskDelete", time.Now(), &err)
+	return s.Next.SyncTaskDelete(ctx, taskId, baseUpdatedAt)
+}
+
+func (s *Storage) IdempotencyBegin(ctx context.Context, scope, key, requestHash string, window time.Duration) (stored db.IdempotencyModel, err error) {
+	defer s.observe("IdempotencyBegin", time.Now(), &err)
+	return s.Next.IdempotencyBegin(ctx, scope, key, requestHash, window)
+}
+
+func (s *Storage) IdempotencyFinish(ctx context.Context, scope, key string, status int, headers map[string]string, body []byte) (err error) {
+	defer s.observe("IdempotencyFinish", time.Now(), &err)
+	return s.Next.IdempotencyFinish(ctx, scope, key, status, headers, body)
+}
+
+func (s *Storage) IdempotencyRelease(ctx context.Context, scope, key string) (err error) {
+	defer s.observe("IdempotencyRelease", time.Now(), &err)
+	return s.Next.IdempotencyRelease(ctx, scope, key)
+}
+
+func (s *Storage) IdempotencyCleanup(ctx context.Context, before time.Time) (err error) {
+	defer s.observe("IdempotencyCleanup", time.Now(), &err)
+	return s.Next.IdempotencyCleanup(ctx, before)
+}
@@ -0,0 +1,295 @@
+// Package migrate applies the versioned database migrations embedded from
+// migrations/. Each migration is a pair of scripts, VERSION.NAME.up.sql and
+// VERSION.NAME.down.sql, applied in a transaction of its own and recorded in
+// the schema_migrations table.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// lockKey is the pg_advisory_lock key that keeps app instances starting
+// together from migrating at the same time.
+const lockKey = 7_146_220_311
+
+//go:embed migrations/*.sql
+var files embed.FS
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	Migration
+	// AppliedAt is zero for pending migrations.
+	AppliedAt time.Time
+}
+
+func (s MigrationStatus) Applied() bool {
+	return !s.AppliedAt.IsZero()
+}
+
+// Migrations returns the embedded migrations ordered by version.
+func Migrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(files, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		versionStr, rest, ok := strings.Cut(entry.Name(), ".")
+		name, direction, ok2 := strings.Cut(strings.TrimSuffix(rest, ".sql"), ".")
+		if !ok || !ok2 || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("migration %s: name must be VERSION.NAME.up.sql or VERSION.NAME.down.sql", entry.Name())
+		}
+		version, err := strconv.ParseInt(versionStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: bad version: %w", entry.Name(), err)
+		}
+
+		script, err := fs.ReadFile(files, path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if m.Name != name {
+			return nil, fmt.Errorf("migration %d: named both %s and %s", version, m.Name, name)
+		}
+		if direction == "up" {
+			m.Up = string(script)
+		} else {
+			m.Down = string(script)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d.%s: both up and down scripts are required", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+type Migrator struct {
+	Pg         *sqlx.DB
+	migrations []Migration
+}
+
+func New(pg *sqlx.DB) (*Migrator, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{Pg: pg, migrations: migrations}, nil
+}
+
+// Up applies every pending migration in order and returns those applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.locked(ctx, func(conn *sqlx.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+			err = run(ctx, conn, migration, migration.Up,
+				`insert into schema_migrations (version, name, applied_at) values ($1, $2, NOW())`,
+				migration.Version, migration.Name)
+			if err != nil {
+				return err
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+
+	return applied, err
+}
+
+// Down reverts the last steps applied migrations, newest first, and returns
+// those reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := m.locked(ctx, func(conn *sqlx.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := done[migration.Version]; !ok {
+				continue
+			}
+			err = run(ctx, conn, migration, migration.Down,
+				`delete from schema_migrations where version=$1`,
+				migration.Version)
+			if err != nil {
+				return err
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+
+	return reverted, err
+}
+
+// Status lists every embedded migration and when it was applied. Versions
+// recorded in the database but unknown to this binary are an error, as the
+// database is then newer than the code.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := m.locked(ctx, func(conn *sqlx.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			statuses = append(statuses, MigrationStatus{Migration: migration, AppliedAt: done[migration.Version]})
+			delete(done, migration.Version)
+		}
+		if len(done) > 0 {
+			unknown := slices.Sorted(maps.Keys(done))
+			return fmt.Errorf("migrations %v are applied but unknown to this build", unknown)
+		}
+		return nil
+	})
+
+	return statuses, err
+}
+
+// Pending lists the embedded migrations not applied yet. Unlike Status it
+// does not wait for the lock, so it is cheap enough for readiness probes.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	var exists bool
+	err := m.Pg.GetContext(ctx, &exists, `select to_regclass('schema_migrations') is not null`)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return m.migrations, nil
+	}
+
+	done, err := appliedVersions(ctx, m.Pg)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, migration := range m.migrations {
+		if _, ok := done[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// locked runs f on one connection holding the advisory lock. The lock
+// belongs to the session, so everything must go through conn.
+func (m *Migrator) locked(ctx context.Context, f func(conn *sqlx.Conn) error) (err error) {
+	conn, err := m.Pg.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, `select pg_advisory_lock($1)`, lockKey)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// The context may be done already, the unlock must still happen.
+		_, unlockErr := conn.ExecContext(context.Background(), `select pg_advisory_unlock($1)`, lockKey)
+		err = errors.Join(err, unlockErr)
+	}()
+
+	// schema_migrations is queried directly rather than through functions
+	// like the rest of the schema, since it has to exist before them.
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version bigint primary key,
+    name text not null,
+    applied_at timestamp without time zone not null
+)`)
+	if err != nil {
+		return err
+	}
+
+	return f(conn)
+}
+
+func appliedVersions(ctx context.Context, conn sqlx.QueryerContext) (map[int64]time.Time, error) {
+	var rows []struct {
+		Version   int64     `db:"version"`
+		AppliedAt time.Time `db:"applied_at"`
+	}
+	err := sqlx.SelectContext(ctx, conn, &rows, `select version, applied_at from schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(map[int64]time.Time, len(rows))
+	for _, row := range rows {
+		done[row.Version] = row.AppliedAt
+	}
+	return done, nil
+}
+
+// run executes script and the bookkeeping statement in one transaction, so
+// a failed migration leaves neither the schema nor schema_migrations
+// changed.
+func run(ctx context.Context, conn *sqlx.Conn, migration Migration, script, record string, args ...interface{}) error {
+	tx, err := conn.BeginTxx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Without arguments lib/pq sends the script as one simple query, which
+	// may hold several statements.
+	_, err = tx.ExecContext(ctx, script)
+	if err != nil {
+		return fmt.Errorf("migration %d.%s: %w", migration.Version, migration.Name, err)
+	}
+	_, err = tx.ExecContext(ctx, record, args...)
+	if err != nil {
+		return fmt.Errorf("migration %d.%s: %w", migration.Version, migration.Name, err)
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,3 @@
+DROP SCHEMA IF EXISTS users;
+
+DROP SCHEMA IF EXISTS tasks;
@@ -0,0 +1,6 @@
+DROP PROCEDURE IF EXISTS tasks.idempotency_cleanup;
+DROP PROCEDURE IF EXISTS tasks.idempotency_release;
+DROP PROCEDURE IF EXISTS tasks.idempotency_finish;
+DROP FUNCTION IF EXISTS tasks.idempotency_begin;
+
+DROP TABLE IF EXISTS tasks.idempotency_keys;
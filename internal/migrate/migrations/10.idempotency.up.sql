@@ -0,0 +1,91 @@
+CREATE TABLE IF NOT EXISTS tasks.idempotency_keys (
+    scope text not null,
+    key text not null,
+    request_hash text not null,
+    status int null,
+    headers json null,
+    body bytea null,
+    created_at timestamp without time zone not null,
+    primary key (scope, key)
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_created_at ON tasks.idempotency_keys (created_at);
+
+-- idempotency_begin claims the key for a new request. If the key is already
+-- taken within the window, the stored row is returned with created=false;
+-- status is null while the first request is still running.
+CREATE OR REPLACE FUNCTION tasks.idempotency_begin(
+    _scope text,
+    _key text,
+    _request_hash text,
+    _window_seconds bigint
+)
+returns table (
+    created boolean,
+    request_hash text,
+    status int,
+    headers text,
+    body bytea,
+    created_at timestamp without time zone
+)
+language plpgsql
+as
+$$
+begin
+    delete from tasks.idempotency_keys k
+        where k.scope=_scope and k.key=_key and k.created_at < NOW() - make_interval(secs => _window_seconds);
+
+    insert into tasks.idempotency_keys (scope, key, request_hash, created_at)
+        values (_scope, _key, _request_hash, NOW())
+        on conflict do nothing;
+
+    if FOUND then
+        return query
+            SELECT true, _request_hash, null::int, null::text, null::bytea, NOW()::timestamp without time zone;
+        return;
+    end if;
+
+    return query
+        SELECT false, k.request_hash, k.status, k.headers::text, k.body, k.created_at from tasks.idempotency_keys k
+            where k.scope=_scope and k.key=_key;
+end;
+$$;
+
+CREATE OR REPLACE PROCEDURE tasks.idempotency_finish(
+    _scope text,
+    _key text,
+    _status int,
+    _headers json,
+    _body bytea
+)
+language plpgsql
+as
+$$
+begin
+    update tasks.idempotency_keys set status=_status, headers=_headers, body=_body
+        where scope=_scope and key=_key;
+end;
+$$;
+
+CREATE OR REPLACE PROCEDURE tasks.idempotency_release(
+    _scope text,
+    _key text
+)
+language plpgsql
+as
+$$
+begin
+    delete from tasks.idempotency_keys where scope=_scope and key=_key and status is null;
+end;
+$$;
+
+CREATE OR REPLACE PROCEDURE tasks.idempotency_cleanup(
+    _before timestamp without time zone
+)
+language plpgsql
+as
+$$
+begin
+    delete from tasks.idempotency_keys where created_at < _before;
+end;
+$$;
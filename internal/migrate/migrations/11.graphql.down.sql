@@ -0,0 +1,3 @@
+DROP FUNCTION IF EXISTS tasks.tasks_list_by_ids;
+DROP FUNCTION IF EXISTS users.users_get_by_login;
+DROP FUNCTION IF EXISTS users.users_list_by_ids;
@@ -0,0 +1,64 @@
+CREATE OR REPLACE FUNCTION users.users_list_by_ids(
+    _ids bigint[]
+)
+returns table (
+    id bigint,
+    login text,
+    f_name text,
+    l_name text,
+    role text,
+    date_registration timestamp without time zone
+)
+language plpgsql
+as
+$$
+begin
+    return query
+        SELECT u.id, u.login, u.f_name, u.l_name, u.role, u.date_registration from users.users u
+            where u.id=any(_ids);
+end;
+$$;
+
+CREATE OR REPLACE FUNCTION users.users_get_by_login(
+    _login text
+)
+returns table (
+    id bigint,
+    login text,
+    f_name text,
+    l_name text,
+    role text,
+    date_registration timestamp without time zone
+)
+language plpgsql
+as
+$$
+begin
+    return query
+        SELECT u.id, u.login, u.f_name, u.l_name, u.role, u.date_registration from users.users u
+            where u.login=_login;
+end;
+$$;
+
+CREATE OR REPLACE FUNCTION tasks.tasks_list_by_ids(
+    _ids bigint[]
+)
+returns table (
+    id bigint,
+    id_user bigint,
+    title text,
+    description text,
+    status text,
+    created_at timestamp without time zone,
+    due_date timestamp without time zone,
+    updated_at timestamp without time zone
+)
+language plpgsql
+as
+$$
+begin
+    return query
+        SELECT t.id, t.id_user, t.title, t.description, t.status::text, t.created_at, t.due_date, t.updated_at from tasks.tasks t
+            where t.id=any(_ids);
+end;
+$$;
@@ -0,0 +1 @@
+DROP FUNCTION IF EXISTS tasks.tasks_batch_apply;
@@ -0,0 +1 @@
+DROP FUNCTION IF EXISTS tasks.tasks_count_by_status;
@@ -0,0 +1,13 @@
+CREATE OR REPLACE FUNCTION tasks.tasks_count_by_status()
+returns table (
+    status text,
+    count bigint
+)
+language plpgsql
+as
+$$
+begin
+    return query
+        SELECT t.status::text, count(*) from tasks.tasks t group by t.status;
+end;
+$$;
@@ -0,0 +1,46 @@
+DROP FUNCTION IF EXISTS tasks.tasks_batch_apply(bigint, text, text, bigint, text, timestamp without time zone, bigint, timestamp without time zone);
+
+-- tasks_batch_apply applies one batch action to one task and returns its id,
+-- or null when there is no such task.
+CREATE OR REPLACE FUNCTION tasks.tasks_batch_apply(
+    _id bigint,
+    _action text,
+    _status text,
+    _id_user bigint,
+    _title text,
+    _due_date timestamp without time zone,
+    _shift_seconds bigint
+)
+returns bigint
+language plpgsql
+as
+$$
+    DECLARE _id_task bigint;
+begin
+    case _action
+        when 'set_status' then
+            update tasks.tasks set (status, updated_at) = (_status::task_status, NOW())
+                where id=_id returning id into _id_task;
+        when 'assign' then
+            update tasks.tasks set (id_user, updated_at) = (_id_user, NOW())
+                where id=_id returning id into _id_task;
+        when 'relabel' then
+            update tasks.tasks set (title, updated_at) = (_title, NOW())
+                where id=_id returning id into _id_task;
+        when 'move_due_date' then
+            if _due_date is not null then
+                update tasks.tasks set (due_date, updated_at) = (_due_date, NOW())
+                    where id=_id returning id into _id_task;
+            else
+                update tasks.tasks set (due_date, updated_at) = (due_date + make_interval(secs => _shift_seconds), NOW())
+                    where id=_id returning id into _id_task;
+            end if;
+        when 'delete' then
+            delete from tasks.tasks where id=_id returning id into _id_task;
+        else
+            raise exception 'unknown batch action %', _action;
+    end case;
+
+    return _id_task;
+end;
+$$;
@@ -0,0 +1,58 @@
+-- tasks_batch_apply no longer reports a shift of a task without a due date
+-- as applied, and refuses to shift a due date before _not_before.
+DROP FUNCTION IF EXISTS tasks.tasks_batch_apply(bigint, text, text, bigint, text, timestamp without time zone, bigint);
+
+-- tasks_batch_apply applies one batch action to one task and returns its id,
+-- or null when there is no such task.
+CREATE OR REPLACE FUNCTION tasks.tasks_batch_apply(
+    _id bigint,
+    _action text,
+    _status text,
+    _id_user bigint,
+    _title text,
+    _due_date timestamp without time zone,
+    _shift_seconds bigint,
+    _not_before timestamp without time zone
+)
+returns bigint
+language plpgsql
+as
+$$
+    DECLARE _id_task bigint;
+    DECLARE _shifted timestamp without time zone;
+begin
+    case _action
+        when 'set_status' then
+            update tasks.tasks set (status, updated_at) = (_status::task_status, NOW())
+                where id=_id returning id into _id_task;
+        when 'assign' then
+            update tasks.tasks set (id_user, updated_at) = (_id_user, NOW())
+                where id=_id returning id into _id_task;
+        when 'relabel' then
+            update tasks.tasks set (title, updated_at) = (_title, NOW())
+                where id=_id returning id into _id_task;
+        when 'move_due_date' then
+            if _due_date is not null then
+                update tasks.tasks set (due_date, updated_at) = (_due_date, NOW())
+                    where id=_id returning id into _id_task;
+            else
+                select t.id, t.due_date + make_interval(secs => _shift_seconds) into _id_task, _shifted
+                    from tasks.tasks t where t.id=_id for update;
+                if _id_task is not null and _shifted is null then
+                    raise exception 'no due date to shift for task %', _id;
+                end if;
+                if _shifted < _not_before then
+                    raise exception 'due date in the past for task %', _id;
+                end if;
+                update tasks.tasks set (due_date, updated_at) = (_shifted, NOW())
+                    where id=_id;
+            end if;
+        when 'delete' then
+            delete from tasks.tasks where id=_id returning id into _id_task;
+        else
+            raise exception 'unknown batch action %', _action;
+    end case;
+
+    return _id_task;
+end;
+$$;
@@ -0,0 +1,45 @@
+-- Events used to take their id when the row changed, so a long transaction
+-- could commit an event with a lower id than ones already read, and readers
+-- going by id would skip it. The triggers now run at commit and hold a lock
+-- until the commit is done, so ids are handed out in commit order.
+CREATE OR REPLACE FUNCTION tasks.events_notify()
+returns trigger
+language plpgsql
+as
+$$
+    DECLARE _payload json;
+    DECLARE _id_entity bigint;
+    DECLARE _id_event bigint;
+begin
+    -- Held until the transaction ends, after its rows become visible.
+    perform pg_advisory_xact_lock(7146220312);
+
+    if TG_OP = 'DELETE' then
+        _payload := row_to_json(OLD);
+        _id_entity := OLD.id;
+    else
+        _payload := row_to_json(NEW);
+        _id_entity := NEW.id;
+    end if;
+
+    insert into tasks.events (entity, action, id_entity, payload, created_at)
+        values (TG_ARGV[0], lower(TG_OP), _id_entity, _payload, NOW())
+        returning id into _id_event;
+
+    perform pg_notify('tasks_events', _id_event::text);
+
+    return null;
+end;
+$$;
+
+DROP TRIGGER IF EXISTS tasks_events ON tasks.tasks;
+CREATE CONSTRAINT TRIGGER tasks_events
+    AFTER INSERT OR UPDATE OR DELETE ON tasks.tasks
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION tasks.events_notify('task');
+
+DROP TRIGGER IF EXISTS comments_events ON tasks.comments;
+CREATE CONSTRAINT TRIGGER comments_events
+    AFTER INSERT OR UPDATE OR DELETE ON tasks.comments
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION tasks.events_notify('comment');
@@ -0,0 +1,29 @@
+DROP FUNCTION IF EXISTS tasks.tasks_delete;
+CREATE OR REPLACE PROCEDURE tasks.tasks_delete(
+    _ids bigint[]
+)
+language plpgsql
+as
+$$
+begin
+    delete from tasks.tasks where id=any(_ids);
+end;
+$$;
+
+DROP FUNCTION IF EXISTS tasks.tasks_update;
+CREATE OR REPLACE PROCEDURE tasks.tasks_update(
+    _id bigint,
+    _title text,
+    _description text,
+    _status text,
+    _due_date timestamp without time zone
+)
+language plpgsql
+as
+$$
+begin
+    update tasks.tasks set (title, description, status, due_date, updated_at) =
+        (_title, _description, _status::task_status, _due_date, NOW())
+        where id=_id;
+end;
+$$;
@@ -0,0 +1,41 @@
+-- tasks_update and tasks_delete return how many tasks they changed, so a
+-- missing task is reported instead of silently succeeding.
+DROP PROCEDURE IF EXISTS tasks.tasks_update;
+CREATE OR REPLACE FUNCTION tasks.tasks_update(
+    _id bigint,
+    _title text,
+    _description text,
+    _status text,
+    _due_date timestamp without time zone
+)
+returns bigint
+language plpgsql
+as
+$$
+    DECLARE _count bigint;
+begin
+    update tasks.tasks set (title, description, status, due_date, updated_at) =
+        (_title, _description, _status::task_status, _due_date, NOW())
+        where id=_id;
+    GET DIAGNOSTICS _count = ROW_COUNT;
+
+    return _count;
+end;
+$$;
+
+DROP PROCEDURE IF EXISTS tasks.tasks_delete;
+CREATE OR REPLACE FUNCTION tasks.tasks_delete(
+    _ids bigint[]
+)
+returns bigint
+language plpgsql
+as
+$$
+    DECLARE _count bigint;
+begin
+    delete from tasks.tasks where id=any(_ids);
+    GET DIAGNOSTICS _count = ROW_COUNT;
+
+    return _count;
+end;
+$$;
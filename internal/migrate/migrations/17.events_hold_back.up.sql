@@ -0,0 +1,104 @@
+-- 15.events_order put ids in commit order by making every writer wait for
+-- one lock at commit. Events are written as they happen again, and readers
+-- hold back instead: an event is only read once every transaction that could
+-- still commit a lower id has ended.
+--
+-- held_until is the first transaction id not yet handed out when the event
+-- got its id. Events are only written by the triggers below, after the row
+-- change gave the transaction an id, so any transaction holding a lower event
+-- id is older than held_until. Once the oldest running transaction is not
+-- older than held_until, every id up to the event's is settled.
+ALTER TABLE tasks.events ADD COLUMN IF NOT EXISTS held_until xid8 not null default '0';
+
+CREATE OR REPLACE FUNCTION tasks.events_notify()
+returns trigger
+language plpgsql
+as
+$$
+    DECLARE _payload json;
+    DECLARE _id_entity bigint;
+    DECLARE _id_event bigint;
+    DECLARE _held_until xid8;
+begin
+    if TG_OP = 'DELETE' then
+        _payload := row_to_json(OLD);
+        _id_entity := OLD.id;
+    else
+        _payload := row_to_json(NEW);
+        _id_entity := NEW.id;
+    end if;
+
+    -- The snapshot is taken after the id, so it covers every transaction
+    -- that took an id before.
+    _id_event := nextval('tasks.events_id_seq');
+    SELECT pg_snapshot_xmax(pg_current_snapshot()) into _held_until;
+
+    insert into tasks.events (id, entity, action, id_entity, payload, created_at, held_until)
+        values (_id_event, TG_ARGV[0], lower(TG_OP), _id_entity, _payload, NOW(), _held_until);
+
+    perform pg_notify('tasks_events', _id_event::text);
+
+    return null;
+end;
+$$;
+
+DROP TRIGGER IF EXISTS tasks_events ON tasks.tasks;
+CREATE TRIGGER tasks_events
+    AFTER INSERT OR UPDATE OR DELETE ON tasks.tasks
+    FOR EACH ROW EXECUTE FUNCTION tasks.events_notify('task');
+
+DROP TRIGGER IF EXISTS comments_events ON tasks.comments;
+CREATE TRIGGER comments_events
+    AFTER INSERT OR UPDATE OR DELETE ON tasks.comments
+    FOR EACH ROW EXECUTE FUNCTION tasks.events_notify('comment');
+
+-- events_settled_id is the highest event id below which no transaction can
+-- still commit an event.
+CREATE OR REPLACE FUNCTION tasks.events_settled_id(
+    _after bigint
+)
+returns bigint
+language plpgsql
+as
+$$
+begin
+    return (SELECT e.id from tasks.events e
+        where e.id > _after and e.held_until <= pg_snapshot_xmin(pg_current_snapshot())
+        order by e.id desc limit 1);
+end;
+$$;
+
+CREATE OR REPLACE FUNCTION tasks.events_list(
+    _after bigint,
+    _limit int
+)
+returns table (
+    id bigint,
+    entity text,
+    action text,
+    id_entity bigint,
+    payload text,
+    created_at timestamp without time zone
+)
+language plpgsql
+as
+$$
+    DECLARE _settled bigint;
+begin
+    _settled := tasks.events_settled_id(_after);
+
+    return query
+        SELECT e.id, e.entity, e.action, e.id_entity, e.payload::text, e.created_at from tasks.events e
+            where e.id > _after and e.id <= _settled order by e.id limit _limit;
+end;
+$$;
+
+CREATE OR REPLACE FUNCTION tasks.events_last_id()
+returns bigint
+language plpgsql
+as
+$$
+begin
+    return coalesce(tasks.events_settled_id(0), 0);
+end;
+$$;
@@ -0,0 +1 @@
+DROP PROCEDURE IF EXISTS tasks.sync_tombstones_cleanup;
@@ -0,0 +1,12 @@
+-- Tombstones are kept for sync_retention; pulls with an older token are
+-- refused and start over with a full sync.
+CREATE OR REPLACE PROCEDURE tasks.sync_tombstones_cleanup(
+    _before timestamp without time zone
+)
+language plpgsql
+as
+$$
+begin
+    delete from tasks.tombstones where deleted_at < _before;
+end;
+$$;
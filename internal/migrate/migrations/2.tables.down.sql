@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS tasks.comments;
+
+DROP TABLE IF EXISTS tasks.tasks;
+
+DROP TABLE IF EXISTS users.users;
+
+DROP TYPE IF EXISTS task_status;
@@ -1,4 +1,12 @@
-CREATE TYPE task_status AS ENUM ('frozen', 'pending', 'in-progress', 'completed');
+-- CREATE TYPE has no IF NOT EXISTS; the check lets databases set up by hand
+-- from the old scripts be brought under migrations.
+DO $$
+begin
+    if not exists (select 1 from pg_type where typname = 'task_status') then
+        CREATE TYPE task_status AS ENUM ('frozen', 'pending', 'in-progress', 'completed');
+    end if;
+end;
+$$;
 
 CREATE TABLE IF NOT EXISTS users.users (
     id bigserial primary key,
@@ -0,0 +1,8 @@
+DROP FUNCTION IF EXISTS tasks.comment_create;
+DROP FUNCTION IF EXISTS tasks.comments_list;
+DROP FUNCTION IF EXISTS users.register;
+DROP FUNCTION IF EXISTS users.auth;
+DROP PROCEDURE IF EXISTS tasks.tasks_delete;
+DROP PROCEDURE IF EXISTS tasks.tasks_update;
+DROP FUNCTION IF EXISTS tasks.tasks_list;
+DROP FUNCTION IF EXISTS tasks.tasks_create;
@@ -0,0 +1,9 @@
+DROP PROCEDURE IF EXISTS tasks.events_cleanup;
+DROP FUNCTION IF EXISTS tasks.events_last_id;
+DROP FUNCTION IF EXISTS tasks.events_list;
+
+DROP TRIGGER IF EXISTS comments_events ON tasks.comments;
+DROP TRIGGER IF EXISTS tasks_events ON tasks.tasks;
+DROP FUNCTION IF EXISTS tasks.events_notify;
+
+DROP TABLE IF EXISTS tasks.events;
@@ -0,0 +1,88 @@
+CREATE TABLE IF NOT EXISTS tasks.events (
+    id bigserial primary key,
+    entity text not null,
+    action text not null,
+    id_entity bigint not null,
+    payload json not null,
+    created_at timestamp without time zone not null
+);
+
+CREATE OR REPLACE FUNCTION tasks.events_notify()
+returns trigger
+language plpgsql
+as
+$$
+    DECLARE _payload json;
+    DECLARE _id_entity bigint;
+    DECLARE _id_event bigint;
+begin
+    if TG_OP = 'DELETE' then
+        _payload := row_to_json(OLD);
+        _id_entity := OLD.id;
+    else
+        _payload := row_to_json(NEW);
+        _id_entity := NEW.id;
+    end if;
+
+    insert into tasks.events (entity, action, id_entity, payload, created_at)
+        values (TG_ARGV[0], lower(TG_OP), _id_entity, _payload, NOW())
+        returning id into _id_event;
+
+    perform pg_notify('tasks_events', _id_event::text);
+
+    return null;
+end;
+$$;
+
+DROP TRIGGER IF EXISTS tasks_events ON tasks.tasks;
+CREATE TRIGGER tasks_events
+    AFTER INSERT OR UPDATE OR DELETE ON tasks.tasks
+    FOR EACH ROW EXECUTE FUNCTION tasks.events_notify('task');
+
+DROP TRIGGER IF EXISTS comments_events ON tasks.comments;
+CREATE TRIGGER comments_events
+    AFTER INSERT OR UPDATE OR DELETE ON tasks.comments
+    FOR EACH ROW EXECUTE FUNCTION tasks.events_notify('comment');
+
+CREATE OR REPLACE FUNCTION tasks.events_list(
+    _after bigint,
+    _limit int
+)
+returns table (
+    id bigint,
+    entity text,
+    action text,
+    id_entity bigint,
+    payload text,
+    created_at timestamp without time zone
+)
+language plpgsql
+as
+$$
+begin
+    return query
+        SELECT e.id, e.entity, e.action, e.id_entity, e.payload::text, e.created_at from tasks.events e
+            where e.id > _after order by e.id limit _limit;
+end;
+$$;
+
+CREATE OR REPLACE FUNCTION tasks.events_last_id()
+returns bigint
+language plpgsql
+as
+$$
+begin
+    return coalesce((SELECT max(e.id) from tasks.events e), 0);
+end;
+$$;
+
+CREATE OR REPLACE PROCEDURE tasks.events_cleanup(
+    _before timestamp without time zone
+)
+language plpgsql
+as
+$$
+begin
+    delete from tasks.events where created_at < _before;
+end;
+$$;
@@ -0,0 +1,39 @@
+DROP FUNCTION IF EXISTS tasks.sync_task_delete;
+DROP FUNCTION IF EXISTS tasks.sync_task_update;
+DROP FUNCTION IF EXISTS tasks.sync_tombstones;
+DROP FUNCTION IF EXISTS tasks.sync_comments;
+DROP FUNCTION IF EXISTS tasks.sync_tasks;
+DROP FUNCTION IF EXISTS tasks.sync_now;
+
+DROP TRIGGER IF EXISTS comments_tombstones ON tasks.comments;
+DROP TRIGGER IF EXISTS tasks_tombstones ON tasks.tasks;
+DROP FUNCTION IF EXISTS tasks.tombstones_create;
+
+DROP TABLE IF EXISTS tasks.tombstones;
+
+DROP INDEX IF EXISTS tasks.comments_updated_at;
+DROP INDEX IF EXISTS tasks.tasks_updated_at;
+
+-- comments_list goes back to the version from 3.procedures, without
+-- updated_at.
+DROP FUNCTION IF EXISTS tasks.comments_list(bigint);
+CREATE OR REPLACE FUNCTION tasks.comments_list(
+    _id_task bigint
+)
+returns table (
+    id bigint,
+    id_user bigint,
+    id_task bigint,
+    content text,
+    created_at timestamp without time zone
+)
+language plpgsql
+as
+$$
+begin
+    return query
+        SELECT c.id, c.id_user, c.id_task, c.content, c.created_at from tasks.comments c where c.id_task=_id_task;
+end;
+$$;
+
+ALTER TABLE tasks.comments DROP COLUMN IF EXISTS updated_at;
@@ -0,0 +1,187 @@
+ALTER TABLE tasks.comments ADD COLUMN IF NOT EXISTS updated_at timestamp without time zone not null default NOW();
+
+CREATE INDEX IF NOT EXISTS tasks_updated_at ON tasks.tasks (updated_at);
+CREATE INDEX IF NOT EXISTS comments_updated_at ON tasks.comments (updated_at);
+
+CREATE TABLE IF NOT EXISTS tasks.tombstones (
+    id bigserial primary key,
+    entity text not null,
+    id_entity bigint not null,
+    deleted_at timestamp without time zone not null
+);
+
+CREATE INDEX IF NOT EXISTS tombstones_deleted_at ON tasks.tombstones (deleted_at);
+
+CREATE OR REPLACE FUNCTION tasks.tombstones_create()
+returns trigger
+language plpgsql
+as
+$$
+begin
+    insert into tasks.tombstones (entity, id_entity, deleted_at)
+        values (TG_ARGV[0], OLD.id, NOW());
+
+    return null;
+end;
+$$;
+
+DROP TRIGGER IF EXISTS tasks_tombstones ON tasks.tasks;
+CREATE TRIGGER tasks_tombstones
+    AFTER DELETE ON tasks.tasks
+    FOR EACH ROW EXECUTE FUNCTION tasks.tombstones_create('task');
+
+DROP TRIGGER IF EXISTS comments_tombstones ON tasks.comments;
+CREATE TRIGGER comments_tombstones
+    AFTER DELETE ON tasks.comments
+    FOR EACH ROW EXECUTE FUNCTION tasks.tombstones_create('comment');
+
+DROP FUNCTION IF EXISTS tasks.comments_list(bigint);
+CREATE OR REPLACE FUNCTION tasks.comments_list(
+    _id_task bigint
+)
+returns table (
+    id bigint,
+    id_user bigint,
+    id_task bigint,
+    content text,
+    created_at timestamp without time zone,
+    updated_at timestamp without time zone
+)
+language plpgsql
+as
+$$
+begin
+    return query
+        SELECT c.id, c.id_user, c.id_task, c.content, c.created_at, c.updated_at from tasks.comments c where c.id_task=_id_task;
+end;
+$$;
+
+--------------------------------
+
+CREATE OR REPLACE FUNCTION tasks.sync_now()
+returns timestamp without time zone
+language plpgsql
+as
+$$
+begin
+    return NOW();
+end;
+$$;
+
+CREATE OR REPLACE FUNCTION tasks.sync_tasks(
+    _since timestamp without time zone,
+    _until timestamp without time zone
+)
+returns table (
+    id bigint,
+    id_user bigint,
+    title text,
+    description text,
+    status text,
+    created_at timestamp without time zone,
+    due_date timestamp without time zone,
+    updated_at timestamp without time zone
+)
+language plpgsql
+as
+$$
+begin
+    return query
+        SELECT t.id, t.id_user, t.title, t.description, t.status::text, t.created_at, t.due_date, t.updated_at from tasks.tasks t
+            where t.updated_at > _since and t.updated_at <= _until order by t.updated_at;
+end;
+$$;
+
+CREATE OR REPLACE FUNCTION tasks.sync_comments(
+    _since timestamp without time zone,
+    _until timestamp without time zone
+)
+returns table (
+    id bigint,
+    id_user bigint,
+    id_task bigint,
+    content text,
+    created_at timestamp without time zone,
+    updated_at timestamp without time zone
+)
+language plpgsql
+as
+$$
+begin
+    return query
+        SELECT c.id, c.id_user, c.id_task, c.content, c.created_at, c.updated_at from tasks.comments c
+            where c.updated_at > _since and c.updated_at <= _until order by c.updated_at;
+end;
+$$;
+
+CREATE OR REPLACE FUNCTION tasks.sync_tombstones(
+    _since timestamp without time zone,
+    _until timestamp without time zone
+)
+returns table (
+    entity text,
+    id_entity bigint,
+    deleted_at timestamp without time zone
+)
+language plpgsql
+as
+$$
+begin
+    return query
+        SELECT t.entity, t.id_entity, t.deleted_at from tasks.tombstones t
+            where t.deleted_at > _since and t.deleted_at <= _until order by t.deleted_at;
+end;
+$$;
+
+CREATE OR REPLACE FUNCTION tasks.sync_task_update(
+    _id bigint,
+    _title text,
+    _description text,
+    _status text,
+    _due_date timestamp without time zone,
+    _base_updated_at timestamp without time zone
+)
+returns text
+language plpgsql
+as
+$$
+begin
+    update tasks.tasks set (title, description, status, due_date, updated_at) =
+        (_title, _description, _status::task_status, _due_date, NOW())
+        where id=_id and (_base_updated_at is null or updated_at <= _base_updated_at);
+
+    if found then
+        return 'applied';
+    end if;
+
+    if exists (SELECT 1 from tasks.tasks t where t.id=_id) then
+        return 'conflict';
+    end if;
+
+    return 'not_found';
+end;
+$$;
+
+CREATE OR REPLACE FUNCTION tasks.sync_task_delete(
+    _id bigint,
+    _base_updated_at timestamp without time zone
+)
+returns text
+language plpgsql
+as
+$$
+begin
+    delete from tasks.tasks
+        where id=_id and (_base_updated_at is null or updated_at <= _base_updated_at);
+
+    if found then
+        return 'applied';
+    end if;
+
+    if exists (SELECT 1 from tasks.tasks t where t.id=_id) then
+        return 'conflict';
+    end if;
+
+    return 'not_found';
+end;
+$$;
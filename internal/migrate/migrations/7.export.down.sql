@@ -0,0 +1,3 @@
+DROP FUNCTION IF EXISTS tasks.comments_list_by_tasks;
+
+DROP INDEX IF EXISTS tasks.comments_id_task;
@@ -0,0 +1,22 @@
+CREATE INDEX IF NOT EXISTS comments_id_task ON tasks.comments (id_task);
+
+CREATE OR REPLACE FUNCTION tasks.comments_list_by_tasks(
+    _ids bigint[]
+)
+returns table (
+    id bigint,
+    id_user bigint,
+    id_task bigint,
+    content text,
+    created_at timestamp without time zone,
+    updated_at timestamp without time zone
+)
+language plpgsql
+as
+$$
+begin
+    return query
+        SELECT c.id, c.id_user, c.id_task, c.content, c.created_at, c.updated_at from tasks.comments c
+            where c.id_task=any(_ids) order by c.id_task, c.created_at;
+end;
+$$;
@@ -0,0 +1,6 @@
+DROP FUNCTION IF EXISTS users.feed_token_user;
+DROP FUNCTION IF EXISTS users.feed_token_set;
+
+DROP INDEX IF EXISTS users.users_feed_token_hash;
+
+ALTER TABLE users.users DROP COLUMN IF EXISTS feed_token_hash;
@@ -0,0 +1,40 @@
+ALTER TABLE users.users ADD COLUMN IF NOT EXISTS feed_token_hash text null;
+
+CREATE UNIQUE INDEX IF NOT EXISTS users_feed_token_hash ON users.users (feed_token_hash);
+
+CREATE OR REPLACE FUNCTION users.feed_token_set(
+    _login text,
+    _feed_token_hash text
+)
+returns bigint
+language plpgsql
+as
+$$
+    DECLARE _id_user bigint;
+begin
+    update users.users set feed_token_hash=_feed_token_hash where login=_login
+        returning id into _id_user;
+
+    if _id_user is null then
+        raise exception 'not found user with given login';
+    end if;
+
+    return _id_user;
+end;
+$$;
+
+CREATE OR REPLACE FUNCTION users.feed_token_user(
+    _feed_token_hash text
+)
+returns table (
+    id bigint,
+    login text
+)
+language plpgsql
+as
+$$
+begin
+    return query
+        SELECT u.id, u.login from users.users u where u.feed_token_hash=_feed_token_hash;
+end;
+$$;
@@ -0,0 +1 @@
+DROP FUNCTION IF EXISTS tasks.comments_get;
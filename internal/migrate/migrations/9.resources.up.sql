@@ -0,0 +1,19 @@
+CREATE OR REPLACE FUNCTION tasks.comments_get(
+    _id bigint
+)
+returns table (
+    id bigint,
+    id_user bigint,
+    id_task bigint,
+    content text,
+    created_at timestamp without time zone,
+    updated_at timestamp without time zone
+)
+language plpgsql
+as
+$$
+begin
+    return query
+        SELECT c.id, c.id_user, c.id_task, c.content, c.created_at, c.updated_at from tasks.comments c where c.id=_id;
+end;
+$$;
@@ -0,0 +1,118 @@
+// Package openapi holds the OpenAPI 3 document of the API and checks it
+// against the routes actually registered.
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Spec is the OpenAPI document, kept by hand next to this file.
+//
+//go:embed openapi.json
+var Spec []byte
+
+// Undocumented is the route name for routes that Verify skips, such as the
+// Swagger UI assets.
+const Undocumented = "openapi-undocumented"
+
+var muxVariablePattern = regexp.MustCompile(`\{([^{}:]+)(:[^{}]*(\{[^{}]*\}[^{}]*)*)?\}`)
+
+var operationMethods = []string{
+	http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete,
+	http.MethodOptions, http.MethodHead, http.MethodPatch, http.MethodTrace,
+}
+
+type document struct {
+	Paths map[string]map[string]json.RawMessage `json:"paths"`
+}
+
+// Operations lists the operations of Spec as "METHOD /path" strings.
+func Operations() ([]string, error) {
+	var doc document
+	err := json.Unmarshal(Spec, &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	var operations []string
+	for path, item := range doc.Paths {
+		for method := range item {
+			method = strings.ToUpper(method)
+			if slices.Contains(operationMethods, method) {
+				operations = append(operations, method+" "+path)
+			}
+		}
+	}
+	slices.Sort(operations)
+
+	return operations, nil
+}
+
+// RouteOperations lists the routes registered on r as "METHOD /path"
+// strings, with path variables written the OpenAPI way. Routes that accept
+// any method count as GET.
+func RouteOperations(r *mux.Router) ([]string, error) {
+	var operations []string
+	err := r.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		if route.GetHandler() == nil || route.GetName() == Undocumented {
+			return nil
+		}
+
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		path := muxVariablePattern.ReplaceAllString(template, "{$1}")
+
+		methods, err := route.GetMethods()
+		if err != nil {
+			methods = []string{http.MethodGet}
+		}
+		for _, method := range methods {
+			operations = append(operations, method+" "+path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(operations)
+
+	return operations, nil
+}
+
+// Verify reports every route registered on r that Spec does not describe and
+// every operation in Spec that has no route.
+func Verify(r *mux.Router) error {
+	documented, err := Operations()
+	if err != nil {
+		return fmt.Errorf("spec can't be read: %w", err)
+	}
+	registered, err := RouteOperations(r)
+	if err != nil {
+		return fmt.Errorf("routes can't be listed: %w", err)
+	}
+
+	var errs []error
+	for _, operation := range registered {
+		if !slices.Contains(documented, operation) {
+			errs = append(errs, fmt.Errorf("route %s is not in the spec", operation))
+		}
+	}
+	for _, operation := range documented {
+		if !slices.Contains(registered, operation) {
+			errs = append(errs, fmt.Errorf("spec operation %s has no route", operation))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -1,9 +1,8 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
-	"io"
-	"log"
 	"net/http"
 
 	"gitlab.com/vitbog/titov-rest/internal/token"
@@ -12,44 +11,37 @@ import (
 type UserInfo struct {
 	Id               int64  `json:"id"`
 	Login            string `json:"login"`
-	FName            string `json:"fname"`
-	LName            string `json:"lname"`
+	FName            string `json:"fname" validate:"max=100"`
+	LName            string `json:"lname" validate:"max=100"`
 	Role             string `json:"role"`
 	DateRegistration string `json:"date_registration"`
 }
 
+// UserCredentials are validated on registration only: Auth must keep
+// accepting whatever existing accounts were created with.
 type UserCredentials struct {
-	Login    string `json:"login"`
-	Password string `json:"password"`
+	Login    string `json:"login" validate:"required,min=3,max=64"`
+	Password string `json:"password" validate:"required,min=6,max=72"`
 }
 
 func (s *Server) Auth(w http.ResponseWriter, r *http.Request) {
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		log.Printf("Error: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
-		return
-	}
-
 	var userCredentials UserCredentials
-	err = json.Unmarshal(body, &userCredentials)
+	err := decodeJSON(r, &userCredentials)
 	if err != nil {
-		log.Printf("Error: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		s.writeError(w, r, err)
 		return
 	}
 
-	roleName, err := s.Db.Auth(userCredentials.Login, userCredentials.Password)
+	roleName, err := s.Db.Auth(r.Context(), userCredentials.Login, userCredentials.Password)
+	s.Metrics.AuthAttempt(err)
 	if err != nil {
-		log.Printf("Error: %v", err)
-		w.WriteHeader(http.StatusUnauthorized)
+		s.writeError(w, r, err)
 		return
 	}
 
 	accessToken, err := token.Generate(userCredentials.Login, roleName, s.JWTSecretKey, s.JWTAccessTime)
 	if err != nil {
-		log.Printf("Error: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		s.writeError(w, r, ErrInternal(err))
 		return
 	}
 
@@ -58,60 +50,68 @@ func (s *Server) Auth(w http.ResponseWriter, r *http.Request) {
 	}{
 		AccessToken: accessToken,
 	}
-	resultString, err := json.Marshal(result)
-	if err != nil {
-		log.Printf("Error: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(resultString)
-	w.WriteHeader(http.StatusOK)
+	writeJSON(w, http.StatusOK, result)
 }
 
 func (s *Server) Register(w http.ResponseWriter, r *http.Request) {
-	body, err := io.ReadAll(r.Body)
+	body, err := readBody(r)
 	if err != nil {
-		log.Printf("Error: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		s.writeError(w, r, err)
 		return
 	}
 
 	var userCredentials UserCredentials
 	err = json.Unmarshal(body, &userCredentials)
 	if err != nil {
-		log.Printf("Error: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		s.writeError(w, r, ErrBadRequest("request body is not valid JSON", err))
 		return
 	}
 
 	var userInfo UserInfo
 	err = json.Unmarshal(body, &userInfo)
 	if err != nil {
-		log.Printf("Error: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		s.writeError(w, r, ErrBadRequest("request body is not valid JSON", err))
 		return
 	}
 
-	_, err = s.Db.Register(userCredentials.Login, userCredentials.Password, DefaultUserRoleName, userInfo.FName, userInfo.LName)
+	err = s.validate(userCredentials, userInfo)
 	if err != nil {
-		log.Printf("Error: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		s.writeError(w, r, err)
+		return
+	}
+
+	_, err = s.Db.Register(r.Context(), userCredentials.Login, userCredentials.Password, DefaultUserRoleName, userInfo.FName, userInfo.LName)
+	if err != nil {
+		s.writeError(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
+// Middleware rejects requests without a valid access token and passes the
+// login from it on in the context, see userLoginFromContext.
 func (s *Server) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		t := r.Header.Get("Authorization")
-		_, err := token.Verify(t, s.JWTSecretKey)
+		userLogin, err := s.userLogin(r)
 		if err != nil {
-			log.Printf("Error: %v", err)
-			w.WriteHeader(http.StatusUnauthorized)
+			s.writeError(w, r, err)
 			return
 		}
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userLoginKey{}, userLogin)))
 	})
 }
+
+// userLogin returns the login from the access token of the request.
+func (s *Server) userLogin(r *http.Request) (string, error) {
+	tok := r.Header.Get("Authorization")
+	userLoginInterface, err := token.Field("login", tok, s.JWTSecretKey)
+	if err != nil {
+		return "", ErrUnauthorized("access token is missing or not valid", err)
+	}
+	userLogin, ok := userLoginInterface.(string)
+	if !ok {
+		return "", ErrUnauthorized("bad login in token", nil)
+	}
+	return userLogin, nil
+}
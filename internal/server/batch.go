@@ -0,0 +1,176 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"gitlab.com/vitbog/titov-rest/internal/db"
+	"gitlab.com/vitbog/titov-rest/internal/filters"
+)
+
+const (
+	BatchStatusApplied    = "applied"
+	BatchStatusNotFound   = "not_found"
+	BatchStatusError      = "error"
+	BatchStatusRolledBack = "rolled_back"
+)
+
+// TaskBatchRequest names the tasks by ids or by filtering, never both.
+type TaskBatchRequest struct {
+	Action    string             `json:"action" validate:"required,oneof=set_status assign relabel move_due_date delete"`
+	Ids       []int64            `json:"ids"`
+	Filtering *filters.Filtering `json:"filtering"`
+	Status    string             `json:"status"`
+	IdUser    int64              `json:"id_user"`
+	Title     string             `json:"title"`
+	DueDate   time.Time          `json:"due_date"`
+	// Shift moves due dates by a Go duration such as "48h" or "-24h"
+	// instead of setting DueDate.
+	Shift string `json:"shift"`
+}
+
+type TaskBatchItemResult struct {
+	Id      int64        `json:"id"`
+	Status  string       `json:"status"`
+	Error   string       `json:"error,omitempty"`
+	Details []FieldError `json:"details,omitempty"`
+}
+
+type TaskBatchResponse struct {
+	Action    string                `json:"action"`
+	Committed bool                  `json:"committed"`
+	Total     int                   `json:"total"`
+	Applied   int                   `json:"applied"`
+	Failed    int                   `json:"failed"`
+	Results   []TaskBatchItemResult `json:"results"`
+}
+
+// TaskBatchActionConvert checks that the request has what its action needs.
+func (s *Server) TaskBatchActionConvert(req TaskBatchRequest) (db.TaskBatchAction, error) {
+	err := s.validate(req)
+	if err != nil {
+		return db.TaskBatchAction{}, err
+	}
+
+	switch {
+	case len(req.Ids) == 0 && req.Filtering == nil:
+		return db.TaskBatchAction{}, ErrValidation(FieldError{Field: "ids", Message: "ids or filtering is required"})
+	case len(req.Ids) > 0 && req.Filtering != nil:
+		return db.TaskBatchAction{}, ErrValidation(FieldError{Field: "filtering", Message: "must not be given with ids"})
+	case len(req.Ids) > maxBatchTasks:
+		return db.TaskBatchAction{}, ErrValidation(FieldError{Field: "ids", Message: fmt.Sprintf("must be at most %d items", maxBatchTasks)})
+	}
+	// A repeated id would be deleted twice, failing the batch, or shifted
+	// twice.
+	if id, ok := repeatedId(req.Ids); ok {
+		return db.TaskBatchAction{}, ErrValidation(FieldError{Field: "ids", Message: fmt.Sprintf("must not repeat id %d", id)})
+	}
+
+	action := db.TaskBatchAction{Action: req.Action}
+	switch req.Action {
+	case db.TaskBatchSetStatus:
+		action.Status = req.Status
+		err = s.validateFields(TaskModelService{Status: req.Status}, "status")
+	case db.TaskBatchAssign:
+		action.IdUser = req.IdUser
+		if req.IdUser <= 0 {
+			err = ErrValidation(FieldError{Field: "id_user", Message: "is required"})
+		}
+	case db.TaskBatchRelabel:
+		action.Title = req.Title
+		err = s.validateFields(TaskModelService{Title: req.Title}, "title")
+	case db.TaskBatchMoveDueDate:
+		switch {
+		case req.Shift != "" && !req.DueDate.IsZero():
+			err = ErrValidation(FieldError{Field: "shift", Message: "must not be given with due_date"})
+		case req.Shift != "":
+			action.Shift, err = time.ParseDuration(req.Shift)
+			if err != nil || action.Shift == 0 {
+				err = ErrValidation(FieldError{Field: "shift", Message: "must be a non-zero duration such as 48h"})
+			}
+			// The shifted dates differ per task, so the storage checks them
+			// the way the notpast rule checks a given due date.
+			if s.DueDateNotInPast {
+				action.NotBefore = time.Now()
+			}
+		default:
+			action.DueDate = req.DueDate
+			err = s.validateFields(TaskModelService{DueDate: req.DueDate}, "due_date")
+		}
+	}
+	if err != nil {
+		return db.TaskBatchAction{}, err
+	}
+
+	return action, nil
+}
+
+// repeatedId is the first id that is in ids more than once.
+func repeatedId(ids []int64) (int64, bool) {
+	seen := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			return id, true
+		}
+		seen[id] = true
+	}
+	return 0, false
+}
+
+// HandlerTasksBatch applies one action to many tasks, named by ids or by a
+// filtering, in a single transaction. Every task gets a result; if any task
+// fails nothing is changed and the reply is 422.
+func (s *Server) HandlerTasksBatch(w http.ResponseWriter, r *http.Request) {
+	var req TaskBatchRequest
+	err := decodeJSON(r, &req)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	action, err := s.TaskBatchActionConvert(req)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	batch, err := s.Db.TasksBatch(r.Context(), req.Ids, req.Filtering, action, maxBatchTasks)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	response := TaskBatchResponse{
+		Action:    req.Action,
+		Committed: batch.Committed,
+		Total:     len(batch.Ids),
+		Results:   make([]TaskBatchItemResult, 0, len(batch.Ids)),
+	}
+	for i, id := range batch.Ids {
+		result := TaskBatchItemResult{Id: id, Status: BatchStatusApplied}
+		switch err := batch.Errs[i]; {
+		case err != nil:
+			apiErr := ErrorFromDb(r.Context(), err)
+			logAPIError(r.Context(), "batch task failed", apiErr, "action", req.Action, "id_task", id)
+			result.Status = BatchStatusError
+			if apiErr.Status == http.StatusNotFound {
+				result.Status = BatchStatusNotFound
+			}
+			result.Error = apiErr.Message
+			result.Details = apiErr.Details
+			response.Failed++
+		case !batch.Committed:
+			result.Status = BatchStatusRolledBack
+		default:
+			response.Applied++
+		}
+		response.Results = append(response.Results, result)
+	}
+
+	status := http.StatusOK
+	if !batch.Committed {
+		status = http.StatusUnprocessableEntity
+	}
+	writeJSON(w, status, response)
+}
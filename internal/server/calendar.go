@@ -0,0 +1,145 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/gorilla/mux"
+	"gitlab.com/vitbog/titov-rest/internal/db"
+	"gitlab.com/vitbog/titov-rest/internal/filters"
+	"gitlab.com/vitbog/titov-rest/internal/ical"
+)
+
+const (
+	calendarProdId    = "-//titov-rest//tasks//EN"
+	calendarUIDDomain = "titov-rest"
+)
+
+var calendarStatuses = map[string]string{
+	db.TaskStatusFrozen:     ical.StatusNeedsAction,
+	db.TaskStatusPending:    ical.StatusNeedsAction,
+	db.TaskStatusInProgress: ical.StatusInProcess,
+	db.TaskStatusCompleted:  ical.StatusCompleted,
+}
+
+func CalendarURL(feedToken string) string {
+	return fmt.Sprintf("/calendar/%s.ics", feedToken)
+}
+
+// HandlerCalendarToken issues a new calendar feed token for the user. Any
+// previously issued feed URL stops working.
+func (s *Server) HandlerCalendarToken(w http.ResponseWriter, r *http.Request) {
+	userLogin, err := s.userLogin(r)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	feedToken, err := db.FeedTokenGenerate()
+	if err != nil {
+		s.writeError(w, r, ErrInternal(err))
+		return
+	}
+
+	err = s.Db.FeedTokenSet(r.Context(), userLogin, feedToken)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	result := struct {
+		FeedToken string `json:"feed_token"`
+		URL       string `json:"url"`
+	}{
+		FeedToken: feedToken,
+		URL:       CalendarURL(feedToken),
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// HandlerCalendar serves the iCalendar feed of the due dates of the feed
+// owner's tasks. The secret token in the path is the only credential, since
+// calendar apps can't send a JWT. Query parameters:
+//
+//	status     only tasks with this status, may be repeated
+//	component  todo (default) for VTODO entries or event for VEVENT entries
+//
+// Tasks have no projects yet, so status is the only filter for now.
+func (s *Server) HandlerCalendar(w http.ResponseWriter, r *http.Request) {
+	feedToken, ok := mux.Vars(r)["feed_token"]
+	if !ok {
+		s.writeError(w, r, ErrBadRequest("no feed token specified", nil))
+		return
+	}
+
+	query := r.URL.Query()
+	statuses := query["status"]
+	for _, status := range statuses {
+		if !db.TaskStatusIsValid(status) {
+			s.writeError(w, r, ErrBadRequest("bad status specified", nil, FieldError{Field: "status", Message: "is not a task status"}))
+			return
+		}
+	}
+
+	component := ical.ComponentTodo
+	switch query.Get("component") {
+	case "", "todo":
+	case "event":
+		component = ical.ComponentEvent
+	default:
+		s.writeError(w, r, ErrBadRequest("bad component specified", nil, FieldError{Field: "component", Message: "must be todo or event"}))
+		return
+	}
+
+	userId, userLogin, err := s.Db.FeedTokenUser(r.Context(), feedToken)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	tasks, err := s.Db.Tasks(r.Context(), filters.Filtering{
+		SortType:   filters.SortTypeAsc,
+		SortColumn: "due_date",
+		Filters:    []filters.Filter{{FieldName: "id_user", Equals: userId}},
+	})
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	calendar := ical.Calendar{
+		ProdId: calendarProdId,
+		Name:   fmt.Sprintf("Tasks of %s", userLogin),
+		Items:  make([]ical.Item, 0, len(tasks)),
+	}
+	for _, t := range tasks {
+		if t.DueDate.IsZero() {
+			continue
+		}
+		if len(statuses) > 0 && !slices.Contains(statuses, string(t.Status)) {
+			continue
+		}
+
+		calendar.Items = append(calendar.Items, ical.Item{
+			Component:    component,
+			UID:          fmt.Sprintf("task-%d@%s", t.Id, calendarUIDDomain),
+			Summary:      t.Title,
+			Description:  t.Description,
+			Status:       calendarStatuses[string(t.Status)],
+			Categories:   []string{string(t.Status)},
+			Created:      t.CreatedAt,
+			LastModified: t.UpdatedAt,
+			Due:          t.DueDate,
+		})
+	}
+
+	w.Header().Set("Content-Type", ical.ContentType)
+	w.Header().Set("Content-Disposition", `inline; filename="tasks.ics"`)
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	err = calendar.Write(w)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "writing calendar", "error", err)
+	}
+}
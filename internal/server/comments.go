@@ -1,129 +1,144 @@
 package server
 
 import (
-	"encoding/json"
-	"io"
-	"log"
+	"fmt"
 	"net/http"
-	"strconv"
 	"time"
 
-	"github.com/gorilla/mux"
 	"gitlab.com/vitbog/titov-rest/internal/filters"
-	"gitlab.com/vitbog/titov-rest/internal/token"
 )
 
 type CommentModelService struct {
 	Id        int64     `json:"id" db:"id"`
 	IdUser    int64     `json:"id_user" db:"id_user"`
 	IdTask    int64     `json:"id_task" db:"id_task"`
-	Content   string    `json:"content" db:"content"`
+	Content   string    `json:"content" db:"content" validate:"required,max=5000"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+func CommentURL(commentId int64) string {
+	return fmt.Sprintf("/comments/%d", commentId)
 }
 
 func (s *Server) HandlerCommentsCreate(w http.ResponseWriter, r *http.Request) {
-	body, err := io.ReadAll(r.Body)
+	var comment CommentModelService
+	err := decodeJSON(r, &comment)
 	if err != nil {
-		log.Printf("Error: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		s.writeError(w, r, err)
 		return
 	}
 
-	var comment CommentModelService
-	err = json.Unmarshal(body, &comment)
+	err = s.validate(comment)
 	if err != nil {
-		log.Printf("Error: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		s.writeError(w, r, err)
 		return
 	}
 
-	taskIdStr, ok := mux.Vars(r)["id_task"]
-	if !ok {
-		log.Printf("Error: %s", "no id specified")
-		w.WriteHeader(http.StatusBadRequest)
+	taskId, err := pathId(r, "id_task")
+	if err != nil {
+		s.writeError(w, r, err)
 		return
 	}
-	taskId, err := strconv.ParseInt(taskIdStr, 10, 64)
+
+	userLogin, err := s.userLogin(r)
 	if err != nil {
-		log.Printf("Error: %s", "bad id specified")
-		w.WriteHeader(http.StatusBadRequest)
+		s.writeError(w, r, err)
 		return
 	}
 
-	tok := r.Header.Get("Authorization")
-	userLoginInterface, err := token.Field("login", tok, s.JWTSecretKey)
-	userLogin, ok := userLoginInterface.(string)
-	if !ok {
-		log.Printf("Error: %s", "bad login in token")
-		w.WriteHeader(http.StatusBadRequest)
+	commentId, err := s.Db.CommentCreate(r.Context(), taskId, userLogin, comment.Content)
+	if err != nil {
+		s.writeError(w, r, err)
 		return
 	}
 
-	_, err = s.Db.CommentCreate(taskId, userLogin, comment.Content)
+	created, err := s.Db.Comment(r.Context(), commentId)
 	if err != nil {
-		log.Printf("Error: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		s.writeError(w, r, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	writeCreated(w, r, routePrefix(r)+CommentURL(commentId), created)
 }
 
-func (s *Server) HandlerComments(w http.ResponseWriter, r *http.Request) {
-	body, err := io.ReadAll(r.Body)
+func (s *Server) HandlerComment(w http.ResponseWriter, r *http.Request) {
+	commentId, err := pathId(r, "id_comment")
 	if err != nil {
-		log.Printf("Error: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		s.writeError(w, r, err)
 		return
 	}
 
+	comment, err := s.Db.Comment(r.Context(), commentId)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, comment)
+}
+
+func (s *Server) HandlerComments(w http.ResponseWriter, r *http.Request) {
 	var filtering filters.Filtering
-	err = json.Unmarshal(body, &filtering)
+	err := decodeJSON(r, &filtering)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	taskId, err := pathId(r, "id_task")
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	comments, err := s.Db.Comments(r.Context(), taskId, filtering)
 	if err != nil {
-		log.Printf("Error: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		s.writeError(w, r, err)
 		return
 	}
 
-	taskIdStr, ok := mux.Vars(r)["id_task"]
-	if !ok {
-		log.Printf("Error: %s", "no id specified")
-		w.WriteHeader(http.StatusBadRequest)
+	if len(comments) == 0 {
+		w.WriteHeader(http.StatusNoContent)
 		return
 	}
-	taskId, err := strconv.ParseInt(taskIdStr, 10, 64)
+	writeJSON(w, http.StatusOK, comments)
+}
+
+// HandlerTaskComments lists the comments of the task in the path matching
+// the query string, see filters.FromQuery.
+func (s *Server) HandlerTaskComments(w http.ResponseWriter, r *http.Request) {
+	filtering, err := queryFiltering(r)
 	if err != nil {
-		log.Printf("Error: %s", "bad id specified")
-		w.WriteHeader(http.StatusBadRequest)
+		s.writeError(w, r, err)
 		return
 	}
 
-	comments, err := s.Db.Comments(taskId, filtering)
+	taskId, err := pathId(r, "id_task")
 	if err != nil {
-		log.Printf("Error: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		s.writeError(w, r, err)
 		return
 	}
 
-	result, err := json.Marshal(comments)
+	_, err = s.Db.Task(r.Context(), taskId)
 	if err != nil {
-		log.Printf("Error: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		s.writeError(w, r, err)
 		return
 	}
 
-	if len(comments) == 0 {
-		w.WriteHeader(http.StatusNoContent)
+	comments, err := s.Db.Comments(r.Context(), taskId, filtering)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(result)
-	w.WriteHeader(http.StatusOK)
+
+	writeJSON(w, http.StatusOK, comments)
 }
 
 func (s *Server) HandlerCommentsUpdate(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusNotImplemented)
+	s.writeError(w, r, &APIError{Status: http.StatusNotImplemented, Code: ErrorCodeNotImplemented, Message: "updating comments is not implemented"})
 }
 
 func (s *Server) HandlerCommentsDelete(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusNotImplemented)
+	s.writeError(w, r, &APIError{Status: http.StatusNotImplemented, Code: ErrorCodeNotImplemented, Message: "deleting comments is not implemented"})
 }
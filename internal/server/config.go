@@ -0,0 +1,583 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gitlab.com/vitbog/titov-rest/internal/db"
+	"gitlab.com/vitbog/titov-rest/internal/tracing"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	DefaultConfigPath    = "./cfg/app.json"
+	DefaultHTTPAddress   = "0.0.0.0:8080"
+	DefaultJWTAccessTime = time.Hour
+	DefaultDbPort        = "5432"
+	DefaultDbSSLMode     = "disable"
+	DefaultSqlitePath    = "./data/app.db"
+	DefaultLogLevel      = slog.LevelInfo
+
+	StoragePostgres = "postgres"
+	StorageSqlite   = "sqlite"
+	StorageMemory   = "memory"
+
+	// ConfigPathEnv names the config file when -config is not given.
+	ConfigPathEnv = "APP_CONFIG"
+
+	redacted = "REDACTED"
+)
+
+// Defaults for the HTTP server limits left out of the config file.
+const (
+	DefaultReadTimeout       = 30 * time.Second
+	DefaultReadHeaderTimeout = 10 * time.Second
+	DefaultWriteTimeout      = 60 * time.Second
+	DefaultIdleTimeout       = 2 * time.Minute
+	DefaultShutdownTimeout   = 30 * time.Second
+	DefaultMaxHeaderBytes    = http.DefaultMaxHeaderBytes
+	DefaultMaxBodyBytes      = 10 << 20
+	DefaultRequestTimeout    = 30 * time.Second
+	DefaultSyncOverlap       = 5 * time.Minute
+	DefaultSyncRetention     = 30 * 24 * time.Hour
+)
+
+// Defaults for the Postgres connection pool, see db.Pool.
+const (
+	DefaultDbMaxOpenConns    = 25
+	DefaultDbMaxIdleConns    = 10
+	DefaultDbConnMaxLifetime = 30 * time.Minute
+	DefaultDbConnMaxIdleTime = 5 * time.Minute
+)
+
+// DefaultRouteTimeouts lifts the deadline of the streamed routes, which stay
+// open for as long as the client reads. Entries of route_timeouts override
+// them.
+var DefaultRouteTimeouts = map[string]time.Duration{
+	"/events":       0,
+	"/tasks/csv":    0,
+	"/tasks/export": 0,
+}
+
+type Config struct {
+	HTTPAddress string
+	// Storage is StoragePostgres, StorageSqlite, or StorageMemory, which
+	// keeps everything in the process and needs no database.
+	Storage           string
+	JWTSecretKey      string
+	JWTAccessTime     time.Duration
+	DueDateNotInPast  bool
+	IdempotencyWindow time.Duration
+	GRPCAddress       string
+	// GraphQLComplexityLimit caps the cost of one GraphQL query, where every
+	// field costs 1 and lists multiply by their expected size.
+	GraphQLComplexityLimit int
+	// ReadTimeout, ReadHeaderTimeout, WriteTimeout, IdleTimeout and
+	// MaxHeaderBytes are passed to http.Server. The write timeout is lifted
+	// for streamed responses, see disableWriteTimeout.
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+	// MaxBodyBytes caps request bodies, see LimitBody.
+	MaxBodyBytes int64
+	// ShutdownTimeout is how long Shutdown waits for in-flight requests.
+	ShutdownTimeout time.Duration
+	// RequestTimeout is the deadline of a request and the storage calls it
+	// makes; RouteTimeouts overrides it by route template. Zero is no
+	// deadline. See Deadline.
+	RequestTimeout time.Duration
+	RouteTimeouts  map[string]time.Duration
+	// SyncOverlap is how far back from the time of a pull its sync token
+	// points, so the next pull sends the changes of that span again. A
+	// change is stamped when its transaction starts but seen when it
+	// commits, so this must be longer than any transaction writing tasks or
+	// comments.
+	SyncOverlap time.Duration
+	// SyncRetention is how long tombstones of deleted rows are kept. A pull
+	// with a token older than that could miss deletions, so it is refused
+	// and the client starts over with a full sync.
+	SyncRetention time.Duration
+	// PgConnectionString is built from the db_* settings, for Postgres
+	// storage only.
+	PgConnectionString string
+	// DbPool sizes the Postgres connection pool.
+	DbPool db.Pool
+	// SqlitePath is the database file for SQLite storage.
+	SqlitePath string
+	// AutoMigrate applies pending migrations at startup.
+	AutoMigrate bool
+	// LogLevel is the least severe level logged.
+	LogLevel slog.Level
+	// TracingExporter is where spans go: tracing.ExporterNone,
+	// tracing.ExporterStdout or tracing.ExporterOTLP, sent to TracingEndpoint.
+	TracingExporter string
+	TracingEndpoint string
+}
+
+// ConfigConvert parses and checks the settings, reporting every problem at
+// once. Settings left empty get their defaults.
+func ConfigConvert(cfgFile ConfigFile) (Config, error) {
+	var errs []error
+
+	cfg := Config{
+		HTTPAddress:            cfgFile.HTTPAddress,
+		Storage:                cfgFile.Storage,
+		JWTSecretKey:           cfgFile.JWTSecretKey,
+		DueDateNotInPast:       cfgFile.DueDateNotInPast,
+		GRPCAddress:            cfgFile.GRPCAddress,
+		GraphQLComplexityLimit: cfgFile.GraphQLComplexityLimit,
+		MaxHeaderBytes:         cfgFile.MaxHeaderBytes,
+		MaxBodyBytes:           cfgFile.MaxBodyBytes,
+		DbPool: db.Pool{
+			MaxOpenConns: cfgFile.DbMaxOpenConns,
+			MaxIdleConns: cfgFile.DbMaxIdleConns,
+		},
+		SqlitePath:      cfgFile.SqlitePath,
+		AutoMigrate:     cfgFile.AutoMigrate,
+		TracingExporter: cfgFile.TracingExporter,
+		TracingEndpoint: cfgFile.TracingEndpoint,
+	}
+	if cfg.HTTPAddress == "" {
+		cfg.HTTPAddress = DefaultHTTPAddress
+	}
+	if cfg.Storage == "" {
+		cfg.Storage = StoragePostgres
+	}
+	cfg.LogLevel = DefaultLogLevel
+	if cfgFile.LogLevel != "" {
+		err := cfg.LogLevel.UnmarshalText([]byte(cfgFile.LogLevel))
+		if err != nil {
+			errs = append(errs, errors.New("log_level: must be debug, info, warn or error"))
+		}
+	}
+	if cfg.TracingExporter == "" {
+		cfg.TracingExporter = tracing.ExporterNone
+	}
+	if cfg.GRPCAddress == "" {
+		cfg.GRPCAddress = DefaultGRPCAddress
+	}
+	if cfg.GraphQLComplexityLimit == 0 {
+		cfg.GraphQLComplexityLimit = DefaultGraphQLComplexityLimit
+	}
+	if cfg.MaxHeaderBytes == 0 {
+		cfg.MaxHeaderBytes = DefaultMaxHeaderBytes
+	}
+	if cfg.MaxBodyBytes == 0 {
+		cfg.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+	if cfg.DbPool.MaxOpenConns == 0 {
+		cfg.DbPool.MaxOpenConns = DefaultDbMaxOpenConns
+	}
+	if cfg.DbPool.MaxIdleConns == 0 {
+		cfg.DbPool.MaxIdleConns = DefaultDbMaxIdleConns
+	}
+
+	durations := []struct {
+		name   string
+		value  string
+		def    time.Duration
+		dst    *time.Duration
+		zeroOk bool
+	}{
+		{"access_time", cfgFile.JWTAccessTime, DefaultJWTAccessTime, &cfg.JWTAccessTime, false},
+		{"idempotency_window", cfgFile.IdempotencyWindow, DefaultIdempotencyWindow, &cfg.IdempotencyWindow, false},
+		{"shutdown_timeout", cfgFile.ShutdownTimeout, DefaultShutdownTimeout, &cfg.ShutdownTimeout, false},
+		// Zero turns the http.Server timeouts off.
+		{"read_timeout", cfgFile.ReadTimeout, DefaultReadTimeout, &cfg.ReadTimeout, true},
+		{"read_header_timeout", cfgFile.ReadHeaderTimeout, DefaultReadHeaderTimeout, &cfg.ReadHeaderTimeout, true},
+		{"write_timeout", cfgFile.WriteTimeout, DefaultWriteTimeout, &cfg.WriteTimeout, true},
+		{"idle_timeout", cfgFile.IdleTimeout, DefaultIdleTimeout, &cfg.IdleTimeout, true},
+		{"request_timeout", cfgFile.RequestTimeout, DefaultRequestTimeout, &cfg.RequestTimeout, true},
+		{"sync_overlap", cfgFile.SyncOverlap, DefaultSyncOverlap, &cfg.SyncOverlap, false},
+		{"sync_retention", cfgFile.SyncRetention, DefaultSyncRetention, &cfg.SyncRetention, false},
+		// Zero keeps connections open for good.
+		{"db_conn_max_lifetime", cfgFile.DbConnMaxLifetime, DefaultDbConnMaxLifetime, &cfg.DbPool.ConnMaxLifetime, true},
+		{"db_conn_max_idle_time", cfgFile.DbConnMaxIdleTime, DefaultDbConnMaxIdleTime, &cfg.DbPool.ConnMaxIdleTime, true},
+	}
+	for _, d := range durations {
+		*d.dst = d.def
+		if d.value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(d.value)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("%s: %w", d.name, err))
+		case parsed < 0 || parsed == 0 && !d.zeroOk:
+			errs = append(errs, fmt.Errorf("%s: must be positive", d.name))
+		default:
+			*d.dst = parsed
+		}
+	}
+
+	if cfg.SyncRetention <= cfg.SyncOverlap {
+		errs = append(errs, errors.New("sync_retention: must be longer than sync_overlap"))
+	}
+	if strings.TrimSpace(cfg.JWTSecretKey) == "" {
+		errs = append(errs, errors.New("secret: is required"))
+	}
+	for _, address := range [][2]string{{"http_address", cfg.HTTPAddress}, {"grpc_address", cfg.GRPCAddress}} {
+		_, _, err := net.SplitHostPort(address[1])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", address[0], err))
+		}
+	}
+	if cfg.GraphQLComplexityLimit < 0 {
+		errs = append(errs, errors.New("graphql_complexity_limit: must be positive"))
+	}
+	if cfg.MaxHeaderBytes < 0 {
+		errs = append(errs, errors.New("max_header_bytes: must be positive"))
+	}
+	if cfg.MaxBodyBytes < 0 {
+		errs = append(errs, errors.New("max_body_bytes: must be positive"))
+	}
+	if cfg.DbPool.MaxOpenConns < 0 {
+		errs = append(errs, errors.New("db_max_open_conns: must be positive"))
+	}
+	if cfg.DbPool.MaxIdleConns < 0 {
+		errs = append(errs, errors.New("db_max_idle_conns: must be positive"))
+	}
+	routeTimeouts, err := routeTimeoutsParse(cfgFile.RouteTimeouts)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("route_timeouts: %w", err))
+	}
+	cfg.RouteTimeouts = maps.Clone(DefaultRouteTimeouts)
+	maps.Copy(cfg.RouteTimeouts, routeTimeouts)
+	switch cfg.TracingExporter {
+	case tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP:
+	default:
+		errs = append(errs, fmt.Errorf("tracing_exporter: must be %s, %s or %s", tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP))
+	}
+
+	switch cfg.Storage {
+	case StoragePostgres:
+		pgConnectionString, err := pgConnectionStringConvert(cfgFile)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		cfg.PgConnectionString = pgConnectionString
+	case StorageSqlite:
+		if cfg.SqlitePath == "" {
+			cfg.SqlitePath = DefaultSqlitePath
+		}
+	case StorageMemory:
+	default:
+		errs = append(errs, fmt.Errorf("storage: must be %s, %s or %s", StoragePostgres, StorageSqlite, StorageMemory))
+	}
+
+	if len(errs) > 0 {
+		return Config{}, errors.Join(errs...)
+	}
+	return cfg, nil
+}
+
+// routeTimeoutsParse parses comma separated ROUTE=DURATION entries, where
+// ROUTE is a route template such as /tasks/{id_task:[0-9]+} and a zero
+// duration is no deadline.
+func routeTimeoutsParse(value string) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		route, durationStr, ok := strings.Cut(entry, "=")
+		route = strings.TrimSpace(route)
+		if !ok || !strings.HasPrefix(route, "/") {
+			return nil, fmt.Errorf("%q: must be ROUTE=DURATION", entry)
+		}
+		duration, err := time.ParseDuration(strings.TrimSpace(durationStr))
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", entry, err)
+		}
+		if duration < 0 {
+			return nil, fmt.Errorf("%q: must be positive", entry)
+		}
+		timeouts[route] = duration
+	}
+	return timeouts, nil
+}
+
+func pgConnectionStringConvert(cfgFile ConfigFile) (string, error) {
+	var errs []error
+	for _, setting := range [][2]string{{"db_host", cfgFile.DbHost}, {"db_user", cfgFile.DbUser}, {"db_name", cfgFile.DbName}} {
+		if setting[1] == "" {
+			errs = append(errs, fmt.Errorf("%s: is required", setting[0]))
+		}
+	}
+
+	port, sslMode := cfgFile.DbPort, cfgFile.DbSSLMode
+	if port == "" {
+		port = DefaultDbPort
+	}
+	if sslMode == "" {
+		sslMode = DefaultDbSSLMode
+	}
+	_, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		errs = append(errs, errors.New("db_port: must be a port number"))
+	}
+
+	if len(errs) > 0 {
+		return "", errors.Join(errs...)
+	}
+
+	return fmt.Sprintf("host=%s user=%s dbname=%s password=%s port=%s sslmode=%s",
+		pgQuote(cfgFile.DbHost), pgQuote(cfgFile.DbUser), pgQuote(cfgFile.DbName), pgQuote(cfgFile.DbPassword), port, pgQuote(sslMode)), nil
+}
+
+// pgQuote quotes a libpq keyword value, so passwords may contain spaces and
+// quotes.
+func pgQuote(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
+
+// ConfigFile holds the settings as they are written in the config file. Each
+// one can also be set with the environment variable in its env tag and the
+// flag named after its json tag, e.g. -access-time; see ConfigLoader.
+// Settings tagged secret are hidden by Redacted.
+type ConfigFile struct {
+	HTTPAddress            string `json:"http_address" yaml:"http_address" env:"APP_HTTP_ADDRESS"`
+	Storage                string `json:"storage" yaml:"storage" env:"APP_STORAGE"`
+	JWTSecretKey           string `json:"secret" yaml:"secret" env:"APP_SECRET" secret:"true"`
+	JWTAccessTime          string `json:"access_time" yaml:"access_time" env:"APP_ACCESS_TIME"`
+	DueDateNotInPast       bool   `json:"due_date_not_in_past" yaml:"due_date_not_in_past" env:"APP_DUE_DATE_NOT_IN_PAST"`
+	IdempotencyWindow      string `json:"idempotency_window" yaml:"idempotency_window" env:"APP_IDEMPOTENCY_WINDOW"`
+	GRPCAddress            string `json:"grpc_address" yaml:"grpc_address" env:"APP_GRPC_ADDRESS"`
+	GraphQLComplexityLimit int    `json:"graphql_complexity_limit" yaml:"graphql_complexity_limit" env:"APP_GRAPHQL_COMPLEXITY_LIMIT"`
+	ReadTimeout            string `json:"read_timeout" yaml:"read_timeout" env:"APP_READ_TIMEOUT"`
+	ReadHeaderTimeout      string `json:"read_header_timeout" yaml:"read_header_timeout" env:"APP_READ_HEADER_TIMEOUT"`
+	WriteTimeout           string `json:"write_timeout" yaml:"write_timeout" env:"APP_WRITE_TIMEOUT"`
+	IdleTimeout            string `json:"idle_timeout" yaml:"idle_timeout" env:"APP_IDLE_TIMEOUT"`
+	ShutdownTimeout        string `json:"shutdown_timeout" yaml:"shutdown_timeout" env:"APP_SHUTDOWN_TIMEOUT"`
+	MaxHeaderBytes         int    `json:"max_header_bytes" yaml:"max_header_bytes" env:"APP_MAX_HEADER_BYTES"`
+	MaxBodyBytes           int64  `json:"max_body_bytes" yaml:"max_body_bytes" env:"APP_MAX_BODY_BYTES"`
+	RequestTimeout         string `json:"request_timeout" yaml:"request_timeout" env:"APP_REQUEST_TIMEOUT"`
+	RouteTimeouts          string `json:"route_timeouts" yaml:"route_timeouts" env:"APP_ROUTE_TIMEOUTS"`
+	SyncOverlap            string `json:"sync_overlap" yaml:"sync_overlap" env:"APP_SYNC_OVERLAP"`
+	SyncRetention          string `json:"sync_retention" yaml:"sync_retention" env:"APP_SYNC_RETENTION"`
+	DbHost                 string `json:"db_host" yaml:"db_host" env:"DB_HOST"`
+	DbPort                 string `json:"db_port" yaml:"db_port" env:"DB_PORT"`
+	DbUser                 string `json:"db_user" yaml:"db_user" env:"DB_USER"`
+	DbPassword             string `json:"db_password" yaml:"db_password" env:"DB_PASSWORD" secret:"true"`
+	DbName                 string `json:"db_name" yaml:"db_name" env:"DB_NAME"`
+	DbSSLMode              string `json:"db_sslmode" yaml:"db_sslmode" env:"DB_SSLMODE"`
+	DbMaxOpenConns         int    `json:"db_max_open_conns" yaml:"db_max_open_conns" env:"DB_MAX_OPEN_CONNS"`
+	DbMaxIdleConns         int    `json:"db_max_idle_conns" yaml:"db_max_idle_conns" env:"DB_MAX_IDLE_CONNS"`
+	DbConnMaxLifetime      string `json:"db_conn_max_lifetime" yaml:"db_conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME"`
+	DbConnMaxIdleTime      string `json:"db_conn_max_idle_time" yaml:"db_conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME"`
+	SqlitePath             string `json:"sqlite_path" yaml:"sqlite_path" env:"APP_SQLITE_PATH"`
+	AutoMigrate            bool   `json:"auto_migrate" yaml:"auto_migrate" env:"APP_AUTO_MIGRATE"`
+	LogLevel               string `json:"log_level" yaml:"log_level" env:"APP_LOG_LEVEL"`
+	TracingExporter        string `json:"tracing_exporter" yaml:"tracing_exporter" env:"APP_TRACING_EXPORTER"`
+	TracingEndpoint        string `json:"tracing_endpoint" yaml:"tracing_endpoint" env:"APP_TRACING_ENDPOINT"`
+}
+
+// DefaultConfigFile is the bottom layer ConfigLoader starts from, so the
+// effective config shows the defaults too.
+func DefaultConfigFile() ConfigFile {
+	return ConfigFile{
+		HTTPAddress:            DefaultHTTPAddress,
+		Storage:                StoragePostgres,
+		JWTAccessTime:          DefaultJWTAccessTime.String(),
+		IdempotencyWindow:      DefaultIdempotencyWindow.String(),
+		GRPCAddress:            DefaultGRPCAddress,
+		GraphQLComplexityLimit: DefaultGraphQLComplexityLimit,
+		ReadTimeout:            DefaultReadTimeout.String(),
+		ReadHeaderTimeout:      DefaultReadHeaderTimeout.String(),
+		WriteTimeout:           DefaultWriteTimeout.String(),
+		IdleTimeout:            DefaultIdleTimeout.String(),
+		ShutdownTimeout:        DefaultShutdownTimeout.String(),
+		MaxHeaderBytes:         DefaultMaxHeaderBytes,
+		MaxBodyBytes:           DefaultMaxBodyBytes,
+		RequestTimeout:         DefaultRequestTimeout.String(),
+		SyncOverlap:            DefaultSyncOverlap.String(),
+		SyncRetention:          DefaultSyncRetention.String(),
+		DbPort:                 DefaultDbPort,
+		DbSSLMode:              DefaultDbSSLMode,
+		DbMaxOpenConns:         DefaultDbMaxOpenConns,
+		DbMaxIdleConns:         DefaultDbMaxIdleConns,
+		DbConnMaxLifetime:      DefaultDbConnMaxLifetime.String(),
+		DbConnMaxIdleTime:      DefaultDbConnMaxIdleTime.String(),
+		SqlitePath:             DefaultSqlitePath,
+		LogLevel:               strings.ToLower(DefaultLogLevel.String()),
+		TracingExporter:        tracing.ExporterNone,
+	}
+}
+
+// Redacted returns a copy with the secret settings hidden, for printing.
+func (c ConfigFile) Redacted() ConfigFile {
+	value := reflect.ValueOf(&c).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		if value.Type().Field(i).Tag.Get("secret") == "true" && !value.Field(i).IsZero() {
+			value.Field(i).SetString(redacted)
+		}
+	}
+	return c
+}
+
+// ConfigLoader layers the settings, each layer overriding the previous one:
+// defaults, the JSON or YAML config file, environment variables (.env, when
+// present, fills the ones not already set) and command line flags.
+type ConfigLoader struct {
+	path  string
+	flags map[string]string
+}
+
+// NewConfigLoader registers -config and a flag per setting on flags; Load
+// must be called after flags are parsed.
+func NewConfigLoader(flags *flag.FlagSet) *ConfigLoader {
+	l := &ConfigLoader{flags: make(map[string]string)}
+
+	flags.StringVar(&l.path, "config", "", fmt.Sprintf("config file, JSON or YAML (env %s, default %s)", ConfigPathEnv, DefaultConfigPath))
+
+	configType := reflect.TypeOf(ConfigFile{})
+	for i := 0; i < configType.NumField(); i++ {
+		field := configType.Field(i)
+		name := configFieldName(field)
+		usage := fmt.Sprintf("config %s (env %s)", name, field.Tag.Get("env"))
+		set := func(value string) error {
+			l.flags[name] = value
+			return nil
+		}
+		if field.Type.Kind() == reflect.Bool {
+			flags.BoolFunc(configFlagName(name), usage, set)
+		} else {
+			flags.Func(configFlagName(name), usage, set)
+		}
+	}
+
+	return l
+}
+
+func (l *ConfigLoader) Load() (ConfigFile, error) {
+	cfgFile := DefaultConfigFile()
+
+	err := godotenv.Load()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return ConfigFile{}, fmt.Errorf("loading .env: %w", err)
+	}
+
+	path, required := l.path, true
+	if path == "" {
+		path = os.Getenv(ConfigPathEnv)
+	}
+	if path == "" {
+		path, required = DefaultConfigPath, false
+	}
+	err = configFileRead(path, &cfgFile)
+	if err != nil && (required || !errors.Is(err, fs.ErrNotExist)) {
+		return ConfigFile{}, err
+	}
+
+	value := reflect.ValueOf(&cfgFile).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		env := field.Tag.Get("env")
+		raw, ok := os.LookupEnv(env)
+		if !ok {
+			continue
+		}
+		err = configFieldSet(value.Field(i), raw)
+		if err != nil {
+			return ConfigFile{}, fmt.Errorf("env %s: %w", env, err)
+		}
+	}
+
+	for i := 0; i < value.NumField(); i++ {
+		name := configFieldName(value.Type().Field(i))
+		raw, ok := l.flags[name]
+		if !ok {
+			continue
+		}
+		err = configFieldSet(value.Field(i), raw)
+		if err != nil {
+			return ConfigFile{}, fmt.Errorf("flag -%s: %w", configFlagName(name), err)
+		}
+	}
+
+	return cfgFile, nil
+}
+
+// configFileRead decodes the file over cfgFile, keeping the settings it
+// leaves out. Unknown settings are an error so typos do not go unnoticed.
+func configFileRead(path string, cfgFile *ConfigFile) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(raw))
+		decoder.KnownFields(true)
+		err = decoder.Decode(cfgFile)
+		if errors.Is(err, io.EOF) {
+			// An empty file sets nothing.
+			err = nil
+		}
+	default:
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(cfgFile)
+	}
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func configFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	return name
+}
+
+func configFlagName(name string) string {
+	return strings.ReplaceAll(name, "_", "-")
+}
+
+func configFieldSet(field reflect.Value, raw string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Bool:
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		field.SetBool(v)
+	case reflect.Int, reflect.Int64:
+		v, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		if field.OverflowInt(v) {
+			return fmt.Errorf("%s is out of range", raw)
+		}
+		field.SetInt(v)
+	default:
+		panic(fmt.Sprintf("config: unsupported kind %s", field.Kind()))
+	}
+	return nil
+}
@@ -1,3 +1,14 @@
 package server
 
 const DefaultUserRoleName = "user"
+
+// exportFlushRows is how many rows streamed exports buffer before flushing
+// them to the client.
+const exportFlushRows = 500
+
+// maxBatchTasks is how many tasks one batch request may change.
+const maxBatchTasks = 1000
+
+// APIV2Prefix is where the resource-oriented routes are mounted; the
+// original RPC-style routes stay at the root.
+const APIV2Prefix = "/api/v2"
@@ -0,0 +1,387 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+	"gitlab.com/vitbog/titov-rest/internal/db"
+)
+
+const (
+	ErrorCodeBadRequest       = "bad_request"
+	ErrorCodeUnauthorized     = "unauthorized"
+	ErrorCodeNotFound         = "not_found"
+	ErrorCodeMethodNotAllowed = "method_not_allowed"
+	ErrorCodeNotAcceptable    = "not_acceptable"
+	ErrorCodeConflict         = "conflict"
+	ErrorCodeTooLarge         = "request_too_large"
+	ErrorCodeValidation       = "validation_failed"
+	ErrorCodeInternal         = "internal_error"
+	ErrorCodeNotImplemented   = "not_implemented"
+	ErrorCodeTimeout          = "timeout"
+	ErrorCodeCanceled         = "canceled"
+	ErrorCodeResyncRequired   = "resync_required"
+)
+
+// StatusClientClosedRequest is the non-standard status, borrowed from nginx,
+// of a request the client gave up on before it was answered.
+const StatusClientClosedRequest = 499
+
+// Postgres error codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pqCodeUniqueViolation     = "23505"
+	pqCodeForeignKeyViolation = "23503"
+	pqCodeNotNullViolation    = "23502"
+	pqCodeInvalidText         = "22P02"
+	pqCodeRaiseException      = "P0001"
+	pqCodeQueryCanceled       = "57014"
+)
+
+var pqKeyColumn = regexp.MustCompile(`^Key \(([^)]+)\)`)
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type ErrorBody struct {
+	Code      string       `json:"code"`
+	Message   string       `json:"message"`
+	Details   []FieldError `json:"details,omitempty"`
+	RequestId string       `json:"request_id,omitempty"`
+}
+
+// ErrorResponse is the body of every error reply.
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+// APIError is an error with everything needed to answer the client. Err is
+// the underlying cause; it is logged but never sent.
+type APIError struct {
+	Status  int
+	Code    string
+	Message string
+	Details []FieldError
+	Err     error
+}
+
+func (e *APIError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+func ErrBadRequest(message string, err error, details ...FieldError) *APIError {
+	return &APIError{Status: http.StatusBadRequest, Code: ErrorCodeBadRequest, Message: message, Details: details, Err: err}
+}
+
+func ErrUnauthorized(message string, err error) *APIError {
+	return &APIError{Status: http.StatusUnauthorized, Code: ErrorCodeUnauthorized, Message: message, Err: err}
+}
+
+func ErrNotFound(message string, err error) *APIError {
+	return &APIError{Status: http.StatusNotFound, Code: ErrorCodeNotFound, Message: message, Err: err}
+}
+
+func ErrConflict(message string, err error) *APIError {
+	return &APIError{Status: http.StatusConflict, Code: ErrorCodeConflict, Message: message, Err: err}
+}
+
+func ErrValidation(details ...FieldError) *APIError {
+	return &APIError{Status: http.StatusUnprocessableEntity, Code: ErrorCodeValidation, Message: "request is not valid", Details: details}
+}
+
+// ErrTimeout is a request that ran past its deadline, see Deadline.
+func ErrTimeout(err error) *APIError {
+	return &APIError{Status: http.StatusServiceUnavailable, Code: ErrorCodeTimeout, Message: "request timed out", Err: err}
+}
+
+// ErrCanceled is a request whose client went away, so nobody reads the reply.
+func ErrCanceled(err error) *APIError {
+	return &APIError{Status: StatusClientClosedRequest, Code: ErrorCodeCanceled, Message: "request was canceled", Err: err}
+}
+
+// ErrResyncRequired is a sync token older than the tombstones kept, see
+// Config.SyncRetention.
+func ErrResyncRequired() *APIError {
+	return &APIError{Status: http.StatusGone, Code: ErrorCodeResyncRequired, Message: "sync token expired, pull without it to sync everything again"}
+}
+
+func ErrInternal(err error) *APIError {
+	return &APIError{Status: http.StatusInternalServerError, Code: ErrorCodeInternal, Message: "internal server error", Err: err}
+}
+
+// ErrorFromDb maps errors returned by the db package to API errors: missing
+// rows become 404, constraint violations 409 or 422, queries past the request
+// deadline 503, queries of a client that went away 499, and everything else
+// 500. ctx is the context err was returned under.
+func ErrorFromDb(ctx context.Context, err error) *APIError {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	switch {
+	case errors.Is(err, db.ErrNotFound), errors.Is(err, sql.ErrNoRows):
+		return ErrNotFound("resource not found", err)
+	case errors.Is(err, db.ErrInvalidCredentials):
+		return ErrUnauthorized("invalid login or password", err)
+	case errors.Is(err, db.ErrFiltering):
+		return ErrBadRequest("filtering is not valid", err)
+	case errors.Is(err, db.ErrBatchTooLarge):
+		return &APIError{Status: http.StatusUnprocessableEntity, Code: ErrorCodeValidation, Message: "too many tasks in the batch", Err: err}
+	case errors.Is(err, db.ErrNoDueDate):
+		return ErrValidation(FieldError{Field: "due_date", Message: "is not set, so it can't be shifted"})
+	case errors.Is(err, db.ErrDueDatePast):
+		return ErrValidation(FieldError{Field: "due_date", Message: "must not be in the past"})
+	case errors.Is(err, db.ErrInvalidValue):
+		return ErrBadRequest("request contains an invalid value", err)
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrTimeout(err)
+	case errors.Is(err, context.Canceled):
+		return ErrCanceled(err)
+	}
+
+	var constraintErr *db.ConstraintError
+	if errors.As(err, &constraintErr) {
+		return constraintError(constraintErr.Field, err)
+	}
+
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return ErrInternal(err)
+	}
+
+	field := pqErr.Column
+	if match := pqKeyColumn.FindStringSubmatch(pqErr.Detail); match != nil {
+		field = match[1]
+	}
+
+	switch pqErr.Code {
+	case pqCodeUniqueViolation:
+		return constraintError(field, fmt.Errorf("%w: %w", db.ErrAlreadyExists, err))
+	case pqCodeForeignKeyViolation:
+		if strings.HasPrefix(pqErr.Message, "update or delete") {
+			return constraintError(field, fmt.Errorf("%w: %w", db.ErrStillReferenced, err))
+		}
+		return constraintError(field, fmt.Errorf("%w: %w", db.ErrMissingReference, err))
+	case pqCodeNotNullViolation:
+		return ErrValidation(fieldDetails(field, "is required")...)
+	case pqCodeInvalidText:
+		return ErrBadRequest("request contains an invalid value", err)
+	case pqCodeQueryCanceled:
+		// lib/pq cancels the query when its context is done; any other
+		// cancellation, such as statement_timeout, is a server error.
+		switch {
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			return ErrTimeout(err)
+		case errors.Is(ctx.Err(), context.Canceled):
+			return ErrCanceled(err)
+		}
+	case pqCodeRaiseException:
+		// The PL/pgSQL functions raise 'not found ...' for missing rows.
+		if strings.HasPrefix(pqErr.Message, "not found") {
+			return ErrNotFound(pqErr.Message, err)
+		}
+	}
+
+	return ErrInternal(err)
+}
+
+// constraintError maps a violated unique or foreign key constraint on field,
+// whichever storage reported it.
+func constraintError(field string, err error) *APIError {
+	switch {
+	case errors.Is(err, db.ErrAlreadyExists):
+		return &APIError{
+			Status:  http.StatusConflict,
+			Code:    ErrorCodeConflict,
+			Message: "resource already exists",
+			Details: fieldDetails(field, "is already taken"),
+			Err:     err,
+		}
+	case errors.Is(err, db.ErrStillReferenced):
+		return &APIError{
+			Status:  http.StatusConflict,
+			Code:    ErrorCodeConflict,
+			Message: "resource is still referenced",
+			Err:     err,
+		}
+	case errors.Is(err, db.ErrMissingReference):
+		return &APIError{
+			Status:  http.StatusUnprocessableEntity,
+			Code:    ErrorCodeValidation,
+			Message: "referenced resource does not exist",
+			Details: fieldDetails(field, "refers to a missing resource"),
+			Err:     err,
+		}
+	}
+	return ErrInternal(err)
+}
+
+func fieldDetails(field, message string) []FieldError {
+	if field == "" {
+		return nil
+	}
+	return []FieldError{{Field: field, Message: message}}
+}
+
+// writeError answers with the JSON error envelope. Errors that are not
+// already *APIError are mapped with ErrorFromDb.
+func (s *Server) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	apiErr := ErrorFromDb(r.Context(), err)
+	requestId := RequestIdFromContext(r.Context())
+
+	logAPIError(r.Context(), "request failed", apiErr, "method", r.Method, "path", r.URL.Path)
+
+	response := ErrorResponse{
+		Error: ErrorBody{
+			Code:      apiErr.Code,
+			Message:   apiErr.Message,
+			Details:   apiErr.Details,
+			RequestId: requestId,
+		},
+	}
+	writeJSON(w, apiErr.Status, response)
+}
+
+// logAPIError logs apiErr with its status and code: client errors as
+// warnings, server errors as errors and requests the client canceled only as
+// information.
+func logAPIError(ctx context.Context, msg string, apiErr *APIError, args ...any) {
+	level := slog.LevelWarn
+	switch {
+	case apiErr.Status == StatusClientClosedRequest:
+		level = slog.LevelInfo
+	case apiErr.Status >= http.StatusInternalServerError:
+		level = slog.LevelError
+	}
+	args = append(args, "status", apiErr.Status, "code", apiErr.Code, "error", apiErr)
+	slog.Log(ctx, level, msg, args...)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	result, err := json.Marshal(v)
+	if err != nil {
+		slog.Error("encoding response", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(result)
+}
+
+// readBody reads the whole request body; bodies over the LimitBody cap are
+// 413.
+func readBody(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, &APIError{
+				Status:  http.StatusRequestEntityTooLarge,
+				Code:    ErrorCodeTooLarge,
+				Message: fmt.Sprintf("request body must be at most %d bytes", maxBytesErr.Limit),
+				Err:     err,
+			}
+		}
+		return nil, ErrBadRequest("request body can't be read", err)
+	}
+	return body, nil
+}
+
+// decodeJSON reads the request body into v, reporting malformed bodies and
+// mistyped fields as 400.
+func decodeJSON(r *http.Request, v interface{}) error {
+	body, err := readBody(r)
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(body, v)
+	if err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			return ErrBadRequest("request body is not valid", err, FieldError{
+				Field:   typeErr.Field,
+				Message: fmt.Sprintf("must be %s", typeErr.Type.String()),
+			})
+		}
+		return ErrBadRequest("request body is not valid JSON", err)
+	}
+
+	return nil
+}
+
+// validate checks every value against its validate tags and reports all
+// violations together as one 422.
+func (s *Server) validate(values ...interface{}) error {
+	var details []FieldError
+	for _, v := range values {
+		for _, violation := range s.Validator.Struct(v) {
+			details = append(details, FieldError{Field: violation.Field, Message: violation.Message})
+		}
+	}
+
+	if len(details) > 0 {
+		return ErrValidation(details...)
+	}
+	return nil
+}
+
+// validateFields is validate limited to the named JSON fields, for partial
+// updates where the untouched fields were valid when they were stored.
+func (s *Server) validateFields(v interface{}, fields ...string) error {
+	var details []FieldError
+	for _, violation := range s.Validator.Struct(v) {
+		if slices.Contains(fields, violation.Field) {
+			details = append(details, FieldError{Field: violation.Field, Message: violation.Message})
+		}
+	}
+
+	if len(details) > 0 {
+		return ErrValidation(details...)
+	}
+	return nil
+}
+
+// pathId parses the named numeric path variable.
+func pathId(r *http.Request, name string) (int64, error) {
+	idStr, ok := mux.Vars(r)[name]
+	if !ok {
+		return 0, ErrBadRequest("no id specified", nil)
+	}
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return 0, ErrBadRequest("bad id specified", err, FieldError{Field: name, Message: "must be an integer"})
+	}
+	return id, nil
+}
+
+func (s *Server) HandlerNotFound(w http.ResponseWriter, r *http.Request) {
+	s.writeError(w, r, ErrNotFound("route not found", nil))
+}
+
+func (s *Server) HandlerMethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	s.writeError(w, r, &APIError{Status: http.StatusMethodNotAllowed, Code: ErrorCodeMethodNotAllowed, Message: "method not allowed"})
+}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gitlab.com/vitbog/titov-rest/internal/db"
+)
+
+const (
+	eventsReplayLimit  = 500
+	eventsPingInterval = 15 * time.Second
+)
+
+// HandlerEvents streams task and comment changes as Server-Sent Events.
+// Every authenticated user can list all tasks and comments, so every event is
+// delivered. Clients resume with the Last-Event-ID header (or the
+// last_event_id query parameter) and get the missed events replayed first.
+func (s *Server) HandlerEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.writeError(w, r, ErrInternal(errors.New("streaming not supported")))
+		return
+	}
+
+	lastEventIdStr := r.Header.Get("Last-Event-ID")
+	if lastEventIdStr == "" {
+		lastEventIdStr = r.URL.Query().Get("last_event_id")
+	}
+	replay := lastEventIdStr != ""
+	var lastEventId int64
+	if replay {
+		var err error
+		lastEventId, err = strconv.ParseInt(lastEventIdStr, 10, 64)
+		if err != nil {
+			s.writeError(w, r, ErrBadRequest("bad last event id specified", err))
+			return
+		}
+	}
+
+	// Streams stay open for as long as the client wants.
+	disableWriteTimeout(w, r)
+
+	// Subscribe before replaying so nothing committed in between is lost;
+	// duplicates are skipped by id below.
+	events, unsubscribe := s.Events.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	if replay {
+		for {
+			missed, err := s.Db.Events(r.Context(), lastEventId, eventsReplayLimit)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "replaying events", "error", err)
+				return
+			}
+			for _, e := range missed {
+				err = writeEvent(w, e)
+				if err != nil {
+					slog.ErrorContext(r.Context(), "writing event", "error", err)
+					return
+				}
+				lastEventId = e.Id
+			}
+			flusher.Flush()
+			if len(missed) < eventsReplayLimit {
+				break
+			}
+		}
+	}
+
+	ping := time.NewTicker(eventsPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.stopping():
+			// Clients reconnect with Last-Event-ID, to another instance if
+			// this one is going away.
+			return
+		case <-ping.C:
+			_, err := fmt.Fprint(w, ": ping\n\n")
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			if e.Id <= lastEventId {
+				continue
+			}
+			err := writeEvent(w, e)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "writing event", "error", err)
+				return
+			}
+			lastEventId = e.Id
+			flusher.Flush()
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, e db.EventModel) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s.%s\ndata: %s\n\n", e.Id, e.Entity, e.Action, data)
+	return err
+}
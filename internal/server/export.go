@@ -0,0 +1,133 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"gitlab.com/vitbog/titov-rest/internal/db"
+	"gitlab.com/vitbog/titov-rest/internal/export"
+	"gitlab.com/vitbog/titov-rest/internal/filters"
+)
+
+// HandlerTasksExport exports the tasks matching the filtering body in the
+// format named by the format query parameter or, failing that, negotiated
+// from the Accept header: CSV, NDJSON, XLSX or Markdown. An unknown format
+// name is a bad request; only a failed negotiation is not acceptable.
+func (s *Server) HandlerTasksExport(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("format")
+	format, ok := export.Negotiate(name, r.Header.Get("Accept"))
+	if !ok && name != "" {
+		names := make([]string, 0, len(export.Formats))
+		for _, f := range export.Formats {
+			names = append(names, f.Name)
+		}
+		s.writeError(w, r, ErrBadRequest("unknown export format", nil, FieldError{Field: "format", Message: "must be one of " + strings.Join(names, ", ")}))
+		return
+	}
+	if !ok {
+		s.writeError(w, r, &APIError{Status: http.StatusNotAcceptable, Code: ErrorCodeNotAcceptable, Message: "no acceptable export format"})
+		return
+	}
+
+	s.exportTasks(w, r, format)
+}
+
+func (s *Server) exportTasks(w http.ResponseWriter, r *http.Request, format export.Format) {
+	opts, err := export.ParseOptions(r.URL.Query(), format, time.Now())
+	if err != nil {
+		s.writeError(w, r, ErrBadRequest(err.Error(), err))
+		return
+	}
+
+	var filtering filters.Filtering
+	err = decodeJSON(r, &filtering)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	disableWriteTimeout(w, r)
+	flusher, _ := w.(http.Flusher)
+	writer := format.New(w, opts)
+	rows := 0
+	// Tasks are written in chunks so comments can be loaded with one query
+	// per chunk instead of one per task.
+	chunk := make([]db.TaskModel, 0, exportFlushRows)
+	writeChunk := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+
+		var comments map[int64][]db.CommentModel
+		if opts.Comments {
+			ids := make([]int64, 0, len(chunk))
+			for _, t := range chunk {
+				ids = append(ids, t.Id)
+			}
+			var err error
+			comments, err = s.Db.CommentsByTasks(r.Context(), ids)
+			if err != nil {
+				return err
+			}
+		}
+
+		if rows == 0 {
+			w.Header().Set("Content-Type", format.ContentType)
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", opts.Filename))
+			w.WriteHeader(http.StatusOK)
+			err := writer.WriteHeader()
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, t := range chunk {
+			err := writer.Write(t, comments[t.Id])
+			if err != nil {
+				return err
+			}
+			rows++
+		}
+		chunk = chunk[:0]
+
+		err := writer.Flush()
+		if err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	}
+
+	err = s.Db.TasksEach(r.Context(), filtering, func(t db.TaskModel) error {
+		chunk = append(chunk, t)
+		if len(chunk) == exportFlushRows {
+			return writeChunk()
+		}
+		return nil
+	})
+	if err == nil {
+		err = writeChunk()
+	}
+	if err == nil && rows > 0 {
+		err = writer.Close()
+	}
+	if err != nil {
+		if rows > 0 {
+			// The status is already sent; abort the connection so the client
+			// sees a truncated download instead of a complete-looking file.
+			slog.ErrorContext(r.Context(), "export aborted", "method", r.Method, "path", r.URL.Path, "error", err)
+			panic(http.ErrAbortHandler)
+		}
+		s.writeError(w, r, err)
+		return
+	}
+
+	if rows == 0 {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
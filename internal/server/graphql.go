@@ -0,0 +1,234 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/graph-gophers/dataloader/v7"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"gitlab.com/vitbog/titov-rest/internal/db"
+	"gitlab.com/vitbog/titov-rest/internal/filters"
+	"gitlab.com/vitbog/titov-rest/internal/graph"
+)
+
+const (
+	DefaultGraphQLComplexityLimit = 5000
+	// graphqlListSize is the page size of lists queried without a limit.
+	graphqlListSize = 20
+	// graphqlMaxListSize is the largest limit a list may be queried with.
+	graphqlMaxListSize = 100
+	// graphqlLoaderWait is how long loaders collect keys before querying.
+	graphqlLoaderWait = 2 * time.Millisecond
+)
+
+// GraphQLResolver resolves the schema in internal/graph, see
+// graphql.resolvers.go.
+type GraphQLResolver struct {
+	s *Server
+}
+
+// graphqlLoaders batch the per-object lookups of one request, so a list of
+// tasks with their comments and authors costs a query per level instead of
+// one per task.
+type graphqlLoaders struct {
+	users    *dataloader.Loader[int64, db.UserModel]
+	tasks    *dataloader.Loader[int64, db.TaskModel]
+	comments *dataloader.Loader[int64, []db.CommentModel]
+}
+
+type graphqlLoadersKey struct{}
+
+// SetupGraphQL builds the /graphql handler with a query complexity limit.
+func (s *Server) SetupGraphQL() error {
+	cfg := graph.Config{Resolvers: &GraphQLResolver{s: s}}
+	cfg.Complexity.Query.Tasks = func(childComplexity int, filtering *graph.FilteringInput) int {
+		var limit *int
+		if filtering != nil {
+			limit = filtering.Limit
+		}
+		return graphqlComplexitySize(limit) * childComplexity
+	}
+	cfg.Complexity.Task.Comments = func(childComplexity int, limit *int) int {
+		return graphqlComplexitySize(limit) * childComplexity
+	}
+
+	srv := handler.New(graph.NewExecutableSchema(cfg))
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.Use(extension.Introspection{})
+	srv.Use(extension.FixedComplexityLimit(s.GraphQLComplexityLimit))
+	srv.SetErrorPresenter(graphqlErrorPresenter)
+
+	s.GraphQL = srv
+
+	return nil
+}
+
+// HandlerGraphQL serves GraphQL queries and mutations over tasks, comments
+// and users.
+func (s *Server) HandlerGraphQL(w http.ResponseWriter, r *http.Request) {
+	userLogin, err := s.userLogin(r)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), userLoginKey{}, userLogin)
+	ctx = context.WithValue(ctx, graphqlLoadersKey{}, s.graphqlLoaders())
+	s.GraphQL.ServeHTTP(w, r.WithContext(ctx))
+}
+
+func (s *Server) graphqlLoaders() *graphqlLoaders {
+	return &graphqlLoaders{
+		users: dataloader.NewBatchedLoader(func(ctx context.Context, ids []int64) []*dataloader.Result[db.UserModel] {
+			users, err := s.Db.UsersByIds(ctx, ids)
+			return loaderResults(ids, users, err)
+		}, dataloader.WithWait[int64, db.UserModel](graphqlLoaderWait)),
+		tasks: dataloader.NewBatchedLoader(func(ctx context.Context, ids []int64) []*dataloader.Result[db.TaskModel] {
+			tasks, err := s.Db.TasksByIds(ctx, ids)
+			return loaderResults(ids, tasks, err)
+		}, dataloader.WithWait[int64, db.TaskModel](graphqlLoaderWait)),
+		comments: dataloader.NewBatchedLoader(func(ctx context.Context, ids []int64) []*dataloader.Result[[]db.CommentModel] {
+			comments, err := s.Db.CommentsByTasks(ctx, ids)
+			results := make([]*dataloader.Result[[]db.CommentModel], len(ids))
+			for i, id := range ids {
+				// A task without comments is not an error.
+				results[i] = &dataloader.Result[[]db.CommentModel]{Data: comments[id], Error: err}
+			}
+			return results
+		}, dataloader.WithWait[int64, []db.CommentModel](graphqlLoaderWait)),
+	}
+}
+
+// loaderResults orders found by ids, as dataloader expects; missing ids get
+// db.ErrNotFound.
+func loaderResults[V any](ids []int64, found map[int64]V, err error) []*dataloader.Result[V] {
+	results := make([]*dataloader.Result[V], len(ids))
+	for i, id := range ids {
+		switch v, ok := found[id]; {
+		case err != nil:
+			results[i] = &dataloader.Result[V]{Error: err}
+		case !ok:
+			results[i] = &dataloader.Result[V]{Error: db.ErrNotFound}
+		default:
+			results[i] = &dataloader.Result[V]{Data: v}
+		}
+	}
+	return results
+}
+
+func loadersFromContext(ctx context.Context) *graphqlLoaders {
+	loaders, _ := ctx.Value(graphqlLoadersKey{}).(*graphqlLoaders)
+	return loaders
+}
+
+// loadOptional loads id, treating a missing object as null rather than an
+// error, e.g. the author of a comment whose user is gone.
+func loadOptional[V any](ctx context.Context, loader *dataloader.Loader[int64, V], id int64) (*V, error) {
+	v, err := loader.Load(ctx, id)()
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// graphqlErrorPresenter presents the *APIError resolvers return like
+// writeError does: the code and field details go to the extensions and the
+// cause only to the log. Other errors, such as a query failing validation or
+// the complexity limit, are meant for the client as they are.
+func graphqlErrorPresenter(ctx context.Context, err error) *gqlerror.Error {
+	gqlErr := graphql.DefaultErrorPresenter(ctx, err)
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return gqlErr
+	}
+
+	logAPIError(ctx, "graphql field failed", apiErr, "field_path", gqlErr.Path.String())
+
+	gqlErr.Message = apiErr.Message
+	gqlErr.Extensions = map[string]interface{}{"code": apiErr.Code}
+	if len(apiErr.Details) > 0 {
+		gqlErr.Extensions["details"] = apiErr.Details
+	}
+	return gqlErr
+}
+
+func TaskModelServiceConvertFromGraphQL(input graph.TaskInput) TaskModelService {
+	task := TaskModelService{
+		Title:   input.Title,
+		Status:  input.Status,
+		DueDate: input.DueDate,
+	}
+	if input.Description != nil {
+		task.Description = *input.Description
+	}
+	return task
+}
+
+// graphqlPageSize is how many items a list queried with limit returns:
+// graphqlListSize without a limit, and a validation error for the field when
+// the limit is not in 1..graphqlMaxListSize.
+func graphqlPageSize(field string, limit *int) (int, error) {
+	if limit == nil {
+		return graphqlListSize, nil
+	}
+	if *limit < 1 || *limit > graphqlMaxListSize {
+		return 0, ErrValidation(FieldError{Field: field, Message: fmt.Sprintf("must be between 1 and %d", graphqlMaxListSize)})
+	}
+	return *limit, nil
+}
+
+// graphqlComplexitySize is the size a list queried with limit is charged as.
+// An invalid limit fails in the resolver, so it is charged as the maximum.
+func graphqlComplexitySize(limit *int) int {
+	size, err := graphqlPageSize("limit", limit)
+	if err != nil {
+		return graphqlMaxListSize
+	}
+	return size
+}
+
+// FilteringConvertFromGraphQL converts f, giving it the page size of
+// graphqlPageSize, so the tasks query never returns more than it is charged
+// for.
+func FilteringConvertFromGraphQL(f *graph.FilteringInput) (filters.Filtering, error) {
+	var filtering filters.Filtering
+	var limit *int
+	if f != nil {
+		limit = f.Limit
+	}
+	size, err := graphqlPageSize("filtering.limit", limit)
+	if err != nil {
+		return filtering, err
+	}
+	filtering.Limit = size
+	if f == nil {
+		return filtering, nil
+	}
+
+	if f.SortType != nil {
+		filtering.SortType = strings.ToLower(string(*f.SortType))
+	}
+	if f.SortColumn != nil {
+		filtering.SortColumn = *f.SortColumn
+	}
+	if f.Offset != nil {
+		filtering.Offset = *f.Offset
+	}
+	for _, filter := range f.Filters {
+		filtering.Filters = append(filtering.Filters, filters.Filter{FieldName: filter.Field, Equals: filter.Equals})
+	}
+	return filtering, nil
+}
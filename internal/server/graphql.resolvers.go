@@ -0,0 +1,220 @@
+package server
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gitlab.com/vitbog/titov-rest/internal/db"
+	"gitlab.com/vitbog/titov-rest/internal/graph"
+)
+
+// Task is the resolver for the task field.
+func (r *commentGraphQLResolver) Task(ctx context.Context, obj *db.CommentModel) (*db.TaskModel, error) {
+	task, err := loadOptional(ctx, loadersFromContext(ctx).tasks, obj.IdTask)
+	if err != nil {
+		return nil, ErrorFromDb(ctx, err)
+	}
+	return task, nil
+}
+
+// Author is the resolver for the author field.
+func (r *commentGraphQLResolver) Author(ctx context.Context, obj *db.CommentModel) (*db.UserModel, error) {
+	user, err := loadOptional(ctx, loadersFromContext(ctx).users, obj.IdUser)
+	if err != nil {
+		return nil, ErrorFromDb(ctx, err)
+	}
+	return user, nil
+}
+
+// CreateTask is the resolver for the createTask field.
+func (r *mutationGraphQLResolver) CreateTask(ctx context.Context, input graph.TaskInput) (*db.TaskModel, error) {
+	task := TaskModelServiceConvertFromGraphQL(input)
+	err := r.s.validate(task)
+	if err != nil {
+		return nil, err
+	}
+
+	taskId, err := r.s.Db.TasksCreate(ctx, userLoginFromContext(ctx), task.Title, task.Description, task.Status, task.DueDate)
+	if err != nil {
+		return nil, ErrorFromDb(ctx, err)
+	}
+
+	created, err := r.s.Db.Task(ctx, taskId)
+	if err != nil {
+		return nil, ErrorFromDb(ctx, err)
+	}
+	return &created, nil
+}
+
+// UpdateTask is the resolver for the updateTask field.
+func (r *mutationGraphQLResolver) UpdateTask(ctx context.Context, id int64, input graph.TaskInput) (*db.TaskModel, error) {
+	task := TaskModelServiceConvertFromGraphQL(input)
+	err := r.s.validateTaskUpdate(ctx, id, task)
+	if err != nil {
+		return nil, ErrorFromDb(ctx, err)
+	}
+
+	err = r.s.Db.TasksUpdate(ctx, id, task.Title, task.Description, task.Status, task.DueDate)
+	if err != nil {
+		return nil, ErrorFromDb(ctx, err)
+	}
+
+	updated, err := r.s.Db.Task(ctx, id)
+	if err != nil {
+		return nil, ErrorFromDb(ctx, err)
+	}
+	return &updated, nil
+}
+
+// DeleteTasks is the resolver for the deleteTasks field.
+func (r *mutationGraphQLResolver) DeleteTasks(ctx context.Context, ids []int64) (bool, error) {
+	err := r.s.Db.TasksDelete(ctx, ids)
+	if err != nil {
+		return false, ErrorFromDb(ctx, err)
+	}
+	return true, nil
+}
+
+// CreateComment is the resolver for the createComment field.
+func (r *mutationGraphQLResolver) CreateComment(ctx context.Context, taskID int64, content string) (*db.CommentModel, error) {
+	comment := CommentModelService{Content: content}
+	err := r.s.validate(comment)
+	if err != nil {
+		return nil, err
+	}
+
+	commentId, err := r.s.Db.CommentCreate(ctx, taskID, userLoginFromContext(ctx), comment.Content)
+	if err != nil {
+		return nil, ErrorFromDb(ctx, err)
+	}
+
+	created, err := r.s.Db.Comment(ctx, commentId)
+	if err != nil {
+		return nil, ErrorFromDb(ctx, err)
+	}
+	return &created, nil
+}
+
+// Tasks is the resolver for the tasks field.
+func (r *queryGraphQLResolver) Tasks(ctx context.Context, filtering *graph.FilteringInput) ([]*db.TaskModel, error) {
+	filt, err := FilteringConvertFromGraphQL(filtering)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks, err := r.s.Db.Tasks(ctx, filt)
+	if err != nil {
+		return nil, ErrorFromDb(ctx, err)
+	}
+
+	result := make([]*db.TaskModel, 0, len(tasks))
+	for i := range tasks {
+		result = append(result, &tasks[i])
+	}
+	return result, nil
+}
+
+// Task is the resolver for the task field.
+func (r *queryGraphQLResolver) Task(ctx context.Context, id int64) (*db.TaskModel, error) {
+	task, err := loadOptional(ctx, loadersFromContext(ctx).tasks, id)
+	if err != nil {
+		return nil, ErrorFromDb(ctx, err)
+	}
+	return task, nil
+}
+
+// Comment is the resolver for the comment field.
+func (r *queryGraphQLResolver) Comment(ctx context.Context, id int64) (*db.CommentModel, error) {
+	comment, err := r.s.Db.Comment(ctx, id)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, ErrorFromDb(ctx, err)
+	}
+	return &comment, nil
+}
+
+// User is the resolver for the user field.
+func (r *queryGraphQLResolver) User(ctx context.Context, id int64) (*db.UserModel, error) {
+	user, err := loadOptional(ctx, loadersFromContext(ctx).users, id)
+	if err != nil {
+		return nil, ErrorFromDb(ctx, err)
+	}
+	return user, nil
+}
+
+// Me is the resolver for the me field.
+func (r *queryGraphQLResolver) Me(ctx context.Context) (*db.UserModel, error) {
+	user, err := r.s.Db.UserByLogin(ctx, userLoginFromContext(ctx))
+	if err != nil {
+		return nil, ErrorFromDb(ctx, err)
+	}
+	return &user, nil
+}
+
+// Status is the resolver for the status field.
+func (r *taskGraphQLResolver) Status(ctx context.Context, obj *db.TaskModel) (string, error) {
+	return string(obj.Status), nil
+}
+
+// DueDate is the resolver for the dueDate field.
+func (r *taskGraphQLResolver) DueDate(ctx context.Context, obj *db.TaskModel) (*time.Time, error) {
+	if obj.DueDate.IsZero() {
+		return nil, nil
+	}
+	return &obj.DueDate, nil
+}
+
+// Author is the resolver for the author field.
+func (r *taskGraphQLResolver) Author(ctx context.Context, obj *db.TaskModel) (*db.UserModel, error) {
+	user, err := loadOptional(ctx, loadersFromContext(ctx).users, obj.IdUser)
+	if err != nil {
+		return nil, ErrorFromDb(ctx, err)
+	}
+	return user, nil
+}
+
+// Comments is the resolver for the comments field.
+func (r *taskGraphQLResolver) Comments(ctx context.Context, obj *db.TaskModel, limit *int) ([]*db.CommentModel, error) {
+	size, err := graphqlPageSize("limit", limit)
+	if err != nil {
+		return nil, err
+	}
+
+	comments, err := loadersFromContext(ctx).comments.Load(ctx, obj.Id)()
+	if err != nil {
+		return nil, ErrorFromDb(ctx, err)
+	}
+	if len(comments) > size {
+		comments = comments[:size]
+	}
+
+	result := make([]*db.CommentModel, 0, len(comments))
+	for i := range comments {
+		result = append(result, &comments[i])
+	}
+	return result, nil
+}
+
+// Comment returns graph.CommentResolver implementation.
+func (r *GraphQLResolver) Comment() graph.CommentResolver { return &commentGraphQLResolver{r} }
+
+// Mutation returns graph.MutationResolver implementation.
+func (r *GraphQLResolver) Mutation() graph.MutationResolver { return &mutationGraphQLResolver{r} }
+
+// Query returns graph.QueryResolver implementation.
+func (r *GraphQLResolver) Query() graph.QueryResolver { return &queryGraphQLResolver{r} }
+
+// Task returns graph.TaskResolver implementation.
+func (r *GraphQLResolver) Task() graph.TaskResolver { return &taskGraphQLResolver{r} }
+
+type commentGraphQLResolver struct{ *GraphQLResolver }
+type mutationGraphQLResolver struct{ *GraphQLResolver }
+type queryGraphQLResolver struct{ *GraphQLResolver }
+type taskGraphQLResolver struct{ *GraphQLResolver }
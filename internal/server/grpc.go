@@ -0,0 +1,355 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"gitlab.com/vitbog/titov-rest/internal/db"
+	"gitlab.com/vitbog/titov-rest/internal/filters"
+	todov1 "gitlab.com/vitbog/titov-rest/internal/pb/todo/v1"
+	"gitlab.com/vitbog/titov-rest/internal/token"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const DefaultGRPCAddress = "0.0.0.0:9090"
+
+type userLoginKey struct{}
+
+// SetupGRPC builds the gRPC server exposing the auth, task and comment
+// operations of the REST API over the same Db and access tokens.
+func (s *Server) SetupGRPC() error {
+	s.GRPC = grpc.NewServer(grpc.ChainUnaryInterceptor(s.grpcDeadline, s.grpcAuth))
+
+	todov1.RegisterAuthServiceServer(s.GRPC, &grpcAuthService{s: s})
+	todov1.RegisterTaskServiceServer(s.GRPC, &grpcTaskService{s: s})
+	todov1.RegisterCommentServiceServer(s.GRPC, &grpcCommentService{s: s})
+
+	return nil
+}
+
+func (s *Server) RunGRPC(address string) error {
+	lis, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	return s.GRPC.Serve(lis)
+}
+
+// grpcDeadline gives calls without a client deadline the RequestTimeout.
+func (s *Server) grpcDeadline(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if _, ok := ctx.Deadline(); !ok && s.RequestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.RequestTimeout)
+		defer cancel()
+	}
+	return handler(ctx, req)
+}
+
+// grpcAuth checks the "authorization" metadata like Middleware checks the
+// header, except for AuthService which issues the tokens.
+func (s *Server) grpcAuth(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if strings.HasPrefix(info.FullMethod, "/"+todov1.AuthService_ServiceDesc.ServiceName+"/") {
+		return handler(ctx, req)
+	}
+
+	var tok string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			tok = values[0]
+		}
+	}
+
+	userLoginInterface, err := token.Field("login", tok, s.JWTSecretKey)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "access token is missing or not valid")
+	}
+	userLogin, ok := userLoginInterface.(string)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "bad login in token")
+	}
+
+	return handler(context.WithValue(ctx, userLoginKey{}, userLogin), req)
+}
+
+func userLoginFromContext(ctx context.Context) string {
+	userLogin, _ := ctx.Value(userLoginKey{}).(string)
+	return userLogin
+}
+
+var grpcCodes = map[int]codes.Code{
+	http.StatusBadRequest:          codes.InvalidArgument,
+	http.StatusUnauthorized:        codes.Unauthenticated,
+	http.StatusNotFound:            codes.NotFound,
+	http.StatusConflict:            codes.FailedPrecondition,
+	http.StatusUnprocessableEntity: codes.InvalidArgument,
+	http.StatusNotImplemented:      codes.Unimplemented,
+	http.StatusServiceUnavailable:  codes.DeadlineExceeded,
+	StatusClientClosedRequest:      codes.Canceled,
+}
+
+// grpcError maps an error the way writeError does, with the field details as
+// a BadRequest error detail.
+func grpcError(ctx context.Context, method string, err error) error {
+	apiErr := ErrorFromDb(ctx, err)
+	logAPIError(ctx, "gRPC request failed", apiErr, "method", method)
+
+	code, ok := grpcCodes[apiErr.Status]
+	if !ok {
+		code = codes.Internal
+	}
+
+	st := status.New(code, apiErr.Message)
+	if len(apiErr.Details) > 0 {
+		badRequest := &errdetails.BadRequest{}
+		for _, d := range apiErr.Details {
+			badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       d.Field,
+				Description: d.Message,
+			})
+		}
+		withDetails, err := st.WithDetails(badRequest)
+		if err == nil {
+			st = withDetails
+		}
+	}
+
+	return st.Err()
+}
+
+// timestamp leaves zero times unset instead of encoding year 1.
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+// timeFromTimestamp is the inverse of timestamp: unset is the zero time, not
+// the Unix epoch AsTime would give.
+func timeFromTimestamp(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
+
+func TaskConvertToProto(t db.TaskModel) *todov1.Task {
+	return &todov1.Task{
+		Id:          t.Id,
+		IdUser:      t.IdUser,
+		Title:       t.Title,
+		Description: t.Description,
+		Status:      string(t.Status),
+		CreatedAt:   timestamp(t.CreatedAt),
+		DueDate:     timestamp(t.DueDate),
+		UpdatedAt:   timestamp(t.UpdatedAt),
+	}
+}
+
+func CommentConvertToProto(c db.CommentModel) *todov1.Comment {
+	return &todov1.Comment{
+		Id:        c.Id,
+		IdUser:    c.IdUser,
+		IdTask:    c.IdTask,
+		Content:   c.Content,
+		CreatedAt: timestamp(c.CreatedAt),
+		UpdatedAt: timestamp(c.UpdatedAt),
+	}
+}
+
+func FilteringConvertFromProto(f *todov1.Filtering) filters.Filtering {
+	filtering := filters.Filtering{
+		SortType:   f.GetSortType(),
+		SortColumn: f.GetSortColumn(),
+		Limit:      int(f.GetLimit()),
+		Offset:     int(f.GetOffset()),
+	}
+	for _, filter := range f.GetFilters() {
+		filtering.Filters = append(filtering.Filters, filters.Filter{
+			FieldName: filter.GetFieldName(),
+			Equals:    filter.GetEquals().AsInterface(),
+		})
+	}
+	return filtering
+}
+
+type grpcAuthService struct {
+	todov1.UnimplementedAuthServiceServer
+	s *Server
+}
+
+func (a *grpcAuthService) Auth(ctx context.Context, req *todov1.AuthRequest) (*todov1.AuthResponse, error) {
+	roleName, err := a.s.Db.Auth(ctx, req.GetLogin(), req.GetPassword())
+	a.s.Metrics.AuthAttempt(err)
+	if err != nil {
+		return nil, grpcError(ctx, "Auth", err)
+	}
+
+	accessToken, err := token.Generate(req.GetLogin(), roleName, a.s.JWTSecretKey, a.s.JWTAccessTime)
+	if err != nil {
+		return nil, grpcError(ctx, "Auth", ErrInternal(err))
+	}
+
+	return &todov1.AuthResponse{AccessToken: accessToken}, nil
+}
+
+func (a *grpcAuthService) Register(ctx context.Context, req *todov1.RegisterRequest) (*emptypb.Empty, error) {
+	userCredentials := UserCredentials{Login: req.GetLogin(), Password: req.GetPassword()}
+	userInfo := UserInfo{FName: req.GetFname(), LName: req.GetLname()}
+	err := a.s.validate(userCredentials, userInfo)
+	if err != nil {
+		return nil, grpcError(ctx, "Register", err)
+	}
+
+	_, err = a.s.Db.Register(ctx, userCredentials.Login, userCredentials.Password, DefaultUserRoleName, userInfo.FName, userInfo.LName)
+	if err != nil {
+		return nil, grpcError(ctx, "Register", err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+type grpcTaskService struct {
+	todov1.UnimplementedTaskServiceServer
+	s *Server
+}
+
+func (t *grpcTaskService) CreateTask(ctx context.Context, req *todov1.CreateTaskRequest) (*todov1.Task, error) {
+	task := TaskModelService{
+		Title:       req.GetTitle(),
+		Description: req.GetDescription(),
+		Status:      req.GetStatus(),
+		DueDate:     timeFromTimestamp(req.GetDueDate()),
+	}
+	err := t.s.validate(task)
+	if err != nil {
+		return nil, grpcError(ctx, "CreateTask", err)
+	}
+
+	taskId, err := t.s.Db.TasksCreate(ctx, userLoginFromContext(ctx), task.Title, task.Description, task.Status, task.DueDate)
+	if err != nil {
+		return nil, grpcError(ctx, "CreateTask", err)
+	}
+
+	created, err := t.s.Db.Task(ctx, taskId)
+	if err != nil {
+		return nil, grpcError(ctx, "CreateTask", err)
+	}
+
+	return TaskConvertToProto(created), nil
+}
+
+func (t *grpcTaskService) GetTask(ctx context.Context, req *todov1.GetTaskRequest) (*todov1.Task, error) {
+	task, err := t.s.Db.Task(ctx, req.GetId())
+	if err != nil {
+		return nil, grpcError(ctx, "GetTask", err)
+	}
+
+	return TaskConvertToProto(task), nil
+}
+
+func (t *grpcTaskService) ListTasks(ctx context.Context, req *todov1.ListTasksRequest) (*todov1.ListTasksResponse, error) {
+	tasks, err := t.s.Db.Tasks(ctx, FilteringConvertFromProto(req.GetFiltering()))
+	if err != nil {
+		return nil, grpcError(ctx, "ListTasks", err)
+	}
+
+	response := &todov1.ListTasksResponse{Tasks: make([]*todov1.Task, 0, len(tasks))}
+	for _, task := range tasks {
+		response.Tasks = append(response.Tasks, TaskConvertToProto(task))
+	}
+
+	return response, nil
+}
+
+func (t *grpcTaskService) UpdateTask(ctx context.Context, req *todov1.UpdateTaskRequest) (*todov1.Task, error) {
+	task := TaskModelService{
+		Title:       req.GetTitle(),
+		Description: req.GetDescription(),
+		Status:      req.GetStatus(),
+		DueDate:     timeFromTimestamp(req.GetDueDate()),
+	}
+	err := t.s.validateTaskUpdate(ctx, req.GetId(), task)
+	if err != nil {
+		return nil, grpcError(ctx, "UpdateTask", err)
+	}
+
+	err = t.s.Db.TasksUpdate(ctx, req.GetId(), task.Title, task.Description, task.Status, task.DueDate)
+	if err != nil {
+		return nil, grpcError(ctx, "UpdateTask", err)
+	}
+
+	updated, err := t.s.Db.Task(ctx, req.GetId())
+	if err != nil {
+		return nil, grpcError(ctx, "UpdateTask", err)
+	}
+
+	return TaskConvertToProto(updated), nil
+}
+
+func (t *grpcTaskService) DeleteTasks(ctx context.Context, req *todov1.DeleteTasksRequest) (*emptypb.Empty, error) {
+	err := t.s.Db.TasksDelete(ctx, req.GetIds())
+	if err != nil {
+		return nil, grpcError(ctx, "DeleteTasks", err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+type grpcCommentService struct {
+	todov1.UnimplementedCommentServiceServer
+	s *Server
+}
+
+func (c *grpcCommentService) CreateComment(ctx context.Context, req *todov1.CreateCommentRequest) (*todov1.Comment, error) {
+	comment := CommentModelService{Content: req.GetContent()}
+	err := c.s.validate(comment)
+	if err != nil {
+		return nil, grpcError(ctx, "CreateComment", err)
+	}
+
+	commentId, err := c.s.Db.CommentCreate(ctx, req.GetIdTask(), userLoginFromContext(ctx), comment.Content)
+	if err != nil {
+		return nil, grpcError(ctx, "CreateComment", err)
+	}
+
+	created, err := c.s.Db.Comment(ctx, commentId)
+	if err != nil {
+		return nil, grpcError(ctx, "CreateComment", err)
+	}
+
+	return CommentConvertToProto(created), nil
+}
+
+func (c *grpcCommentService) GetComment(ctx context.Context, req *todov1.GetCommentRequest) (*todov1.Comment, error) {
+	comment, err := c.s.Db.Comment(ctx, req.GetId())
+	if err != nil {
+		return nil, grpcError(ctx, "GetComment", err)
+	}
+
+	return CommentConvertToProto(comment), nil
+}
+
+func (c *grpcCommentService) ListComments(ctx context.Context, req *todov1.ListCommentsRequest) (*todov1.ListCommentsResponse, error) {
+	comments, err := c.s.Db.Comments(ctx, req.GetIdTask(), FilteringConvertFromProto(req.GetFiltering()))
+	if err != nil {
+		return nil, grpcError(ctx, "ListComments", err)
+	}
+
+	response := &todov1.ListCommentsResponse{Comments: make([]*todov1.Comment, 0, len(comments))}
+	for _, comment := range comments {
+		response.Comments = append(response.Comments, CommentConvertToProto(comment))
+	}
+
+	return response, nil
+}
@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"gitlab.com/vitbog/titov-rest/internal/db"
+	"gitlab.com/vitbog/titov-rest/internal/migrate"
+)
+
+// Version is the release of the binary, set when building with
+// -ldflags "-X gitlab.com/vitbog/titov-rest/internal/server.Version=v1.2.3".
+var Version = "dev"
+
+// readyTimeout bounds the checks of one readiness probe.
+const readyTimeout = 2 * time.Second
+
+const (
+	HealthStatusOk       = "ok"
+	HealthStatusFailed   = "failed"
+	HealthStatusSkipped  = "skipped"
+	HealthStatusReady    = "ready"
+	HealthStatusNotReady = "not_ready"
+)
+
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	CommitAt  string `json:"commit_time,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+type Health struct {
+	Status        string    `json:"status"`
+	Build         BuildInfo `json:"build"`
+	StartedAt     time.Time `json:"started_at"`
+	UptimeSeconds int64     `json:"uptime_seconds"`
+	// Checks is only filled in by readiness probes.
+	Checks map[string]HealthCheck `json:"checks,omitempty"`
+}
+
+type HealthCheck struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// buildInfo is Version with the commit the Go toolchain stamped into the
+// binary, when it was built from a checkout.
+func buildInfo() BuildInfo {
+	info := BuildInfo{Version: Version, GoVersion: runtime.Version()}
+
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	for _, setting := range build.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			info.Commit = setting.Value
+		case "vcs.time":
+			info.CommitAt = setting.Value
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+	return info
+}
+
+// SetupHealth prepares the probes. It must run after SetupDb.
+func (s *Server) SetupHealth() error {
+	s.startedAt = time.Now()
+	s.build = buildInfo()
+
+	if pg, ok := storageUnwrap(s.Db).(*db.Db); ok {
+		migrator, err := migrate.New(&pg.Pg)
+		if err != nil {
+			return err
+		}
+		s.migrator = migrator
+	}
+
+	return nil
+}
+
+// storageUnwrap returns the storage underneath decorators such as the
+// metrics one.
+func storageUnwrap(storage db.Storage) db.Storage {
+	for {
+		wrapper, ok := storage.(interface{ Unwrap() db.Storage })
+		if !ok {
+			return storage
+		}
+		storage = wrapper.Unwrap()
+	}
+}
+
+func (s *Server) health(status string) Health {
+	return Health{
+		Status:        status,
+		Build:         s.build,
+		StartedAt:     s.startedAt.UTC(),
+		UptimeSeconds: int64(time.Since(s.startedAt).Seconds()),
+	}
+}
+
+// HandlerHealthz answers as long as the process serves requests, for
+// liveness probes.
+func (s *Server) HandlerHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.health(HealthStatusOk))
+}
+
+// HandlerReadyz answers 200 when the storage is reachable, every migration
+// is applied and the background workers run, 503 otherwise, for readiness
+// probes.
+func (s *Server) HandlerReadyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+
+	checks := map[string]HealthCheck{
+		"db":         healthCheck(s.Db.Ping(ctx)),
+		"migrations": s.readyMigrations(ctx),
+		"workers":    healthCheck(s.readyWorkers()),
+	}
+
+	health, status := s.health(HealthStatusReady), http.StatusOK
+	for _, check := range checks {
+		if check.Status == HealthStatusFailed {
+			health.Status, status = HealthStatusNotReady, http.StatusServiceUnavailable
+		}
+	}
+	health.Checks = checks
+
+	writeJSON(w, status, health)
+}
+
+func healthCheck(err error) HealthCheck {
+	if err != nil {
+		return HealthCheck{Status: HealthStatusFailed, Error: err.Error()}
+	}
+	return HealthCheck{Status: HealthStatusOk}
+}
+
+// readyMigrations is skipped for storages without migrations.
+func (s *Server) readyMigrations(ctx context.Context) HealthCheck {
+	if s.migrator == nil {
+		return HealthCheck{Status: HealthStatusSkipped}
+	}
+
+	pending, err := s.migrator.Pending(ctx)
+	if err != nil {
+		return healthCheck(err)
+	}
+	if len(pending) > 0 {
+		return healthCheck(fmt.Errorf("%d migrations pending, first %d.%s", len(pending), pending[0].Version, pending[0].Name))
+	}
+	return healthCheck(nil)
+}
+
+func (s *Server) readyWorkers() error {
+	select {
+	case <-s.stopping():
+		return fmt.Errorf("shutting down")
+	default:
+	}
+
+	if started, running := s.workersStarted.Load(), s.workersRunning.Load(); running < started {
+		return fmt.Errorf("%d of %d background workers stopped", started-running, started)
+	}
+	if s.Events != nil && !s.Events.Running() {
+		return fmt.Errorf("events broker stopped")
+	}
+	return nil
+}
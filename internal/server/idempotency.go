@@ -0,0 +1,197 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	DefaultIdempotencyWindow = 24 * time.Hour
+	idempotencyCleanupPeriod = time.Hour
+	idempotencyRetryAfter    = 1
+	maxIdempotencyKeyLength  = 255
+)
+
+// Only these response headers are stored and replayed; X-Request-ID belongs
+// to the retry, not to the original request.
+var idempotencyHeaders = []string{"Content-Type", "Location", PreferenceAppliedHeader}
+
+// Idempotency makes a create endpoint safe to retry. The first request with
+// an Idempotency-Key header runs as usual and its response is stored for the
+// configured window; repeats with the same body get that response replayed,
+// while reusing the key for a different request is a 409. Keys are scoped to
+// the user the Middleware in front authenticated. Anonymous requests, such as
+// /register, have no user, so each is scoped by the request itself and a key
+// only ever replays the same request.
+// Responses with a 5xx status are not stored and the key may be retried.
+func (s *Server) Idempotency(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !idempotencyKeyIsValid(key) {
+			s.writeError(w, r, ErrBadRequest("bad idempotency key specified", nil, FieldError{
+				Field:   IdempotencyKeyHeader,
+				Message: "must be 1 to 255 printable ASCII characters",
+			}))
+			return
+		}
+
+		body, err := readBody(r)
+		if err != nil {
+			s.writeError(w, r, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		requestHash := idempotencyRequestHash(r, body)
+		// Logins are at most 64 characters, so they never collide with this.
+		scope := userLoginFromContext(r.Context())
+		if scope == "" {
+			scope = "anonymous:" + requestHash
+		}
+
+		stored, err := s.Db.IdempotencyBegin(r.Context(), scope, key, requestHash, s.IdempotencyWindow)
+		if err != nil {
+			s.writeError(w, r, err)
+			return
+		}
+
+		if !stored.Created {
+			switch {
+			case stored.RequestHash != requestHash:
+				s.writeError(w, r, ErrConflict("idempotency key was already used for a different request", nil))
+			case stored.Status == 0:
+				w.Header().Set("Retry-After", strconv.Itoa(idempotencyRetryAfter))
+				s.writeError(w, r, ErrConflict("request with this idempotency key is still being processed", nil))
+			default:
+				for name, value := range stored.Headers {
+					w.Header().Set(name, value)
+				}
+				w.Header().Set(IdempotentReplayedHeader, "true")
+				w.WriteHeader(stored.Status)
+				w.Write(stored.Body)
+			}
+			return
+		}
+
+		// Releasing and finishing the key must happen even when the client
+		// has gone away.
+		ctx := context.WithoutCancel(r.Context())
+		finished := false
+		defer func() {
+			// The handler failed or panicked: free the key for a retry.
+			if !finished {
+				err := s.Db.IdempotencyRelease(ctx, scope, key)
+				if err != nil {
+					slog.ErrorContext(ctx, "idempotency release", "error", err)
+				}
+			}
+		}()
+
+		recorder := &idempotencyRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if status >= http.StatusInternalServerError {
+			return
+		}
+
+		headers := make(map[string]string, len(idempotencyHeaders))
+		for _, name := range idempotencyHeaders {
+			if value := w.Header().Get(name); value != "" {
+				headers[name] = value
+			}
+		}
+
+		err = s.Db.IdempotencyFinish(ctx, scope, key, status, headers, recorder.body.Bytes())
+		if err != nil {
+			slog.ErrorContext(ctx, "idempotency finish", "error", err)
+			return
+		}
+		finished = true
+	})
+}
+
+// SetupIdempotency starts removing expired idempotency keys in the background.
+func (s *Server) SetupIdempotency() error {
+	s.background(func(stopping <-chan struct{}) {
+		ticker := time.NewTicker(idempotencyCleanupPeriod)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stopping:
+				return
+			case <-ticker.C:
+				err := s.Db.IdempotencyCleanup(context.Background(), time.Now().Add(-s.IdempotencyWindow))
+				if err != nil {
+					slog.Error("idempotency cleanup", "error", err)
+				}
+			}
+		}
+	})
+
+	return nil
+}
+
+func idempotencyKeyIsValid(candidate string) bool {
+	if len(candidate) > maxIdempotencyKeyLength {
+		return false
+	}
+	for _, c := range candidate {
+		if c < ' ' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// idempotencyRequestHash identifies the request a key was first used with:
+// the same key on another endpoint or with another body is a mismatch.
+func idempotencyRequestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method)
+	io.WriteString(h, "\n")
+	io.WriteString(h, r.URL.Path)
+	io.WriteString(h, "\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// idempotencyRecorder passes the response through while keeping a copy of
+// the status and body to store.
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *idempotencyRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *idempotencyRecorder) Write(p []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(p)
+	return rec.ResponseWriter.Write(p)
+}
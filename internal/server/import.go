@@ -0,0 +1,216 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gocarina/gocsv"
+	"gitlab.com/vitbog/titov-rest/internal/db"
+)
+
+const (
+	ImportModeAtomic     = "atomic"
+	ImportModeBestEffort = "best_effort"
+)
+
+func IsValidImportMode(candidate string) bool {
+	return candidate == ImportModeAtomic || candidate == ImportModeBestEffort
+}
+
+// TaskCSVRow mirrors the columns HandlerTasksCSV writes for db.TaskModel.
+// Id, IdUser, CreatedAt and UpdatedAt are accepted but ignored: imported
+// tasks belong to the importing user and get fresh timestamps.
+type TaskCSVRow struct {
+	Id          string `csv:"Id"`
+	IdUser      string `csv:"IdUser"`
+	Title       string `csv:"Title"`
+	Description string `csv:"Description"`
+	Status      string `csv:"Status"`
+	CreatedAt   string `csv:"CreatedAt"`
+	DueDate     string `csv:"DueDate"`
+	UpdatedAt   string `csv:"UpdatedAt"`
+}
+
+type ImportError struct {
+	Row     int    `json:"row"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+type ImportSummary struct {
+	DryRun   bool          `json:"dry_run"`
+	Mode     string        `json:"mode"`
+	Total    int           `json:"total"`
+	Valid    int           `json:"valid"`
+	Imported int           `json:"imported"`
+	Failed   int           `json:"failed"`
+	Ids      []int64       `json:"ids"`
+	Errors   []ImportError `json:"errors"`
+}
+
+func TaskCSVRowConvert(row TaskCSVRow) (db.TaskModel, []ImportError) {
+	var errs []ImportError
+
+	title := strings.TrimSpace(row.Title)
+	if title == "" {
+		errs = append(errs, ImportError{Field: "Title", Message: "title is empty"})
+	}
+
+	if !db.TaskStatusIsValid(row.Status) {
+		errs = append(errs, ImportError{Field: "Status", Message: "task status is not valid"})
+	}
+
+	var dueDate time.Time
+	if row.DueDate != "" {
+		var err error
+		dueDate, err = time.Parse(time.RFC3339, row.DueDate)
+		if err != nil {
+			errs = append(errs, ImportError{Field: "DueDate", Message: "due date is not an RFC 3339 timestamp"})
+		}
+	}
+
+	return db.TaskModel{
+		Title:       title,
+		Description: row.Description,
+		Status:      db.TaskStatus(row.Status),
+		DueDate:     dueDate,
+	}, errs
+}
+
+// taskCSVColumns names the CSV column of each validated TaskModelService
+// field, so import errors point at the column the user wrote.
+var taskCSVColumns = map[string]string{
+	"title":       "Title",
+	"description": "Description",
+	"status":      "Status",
+	"due_date":    "DueDate",
+}
+
+// validateImportRow applies the rules of TaskModelService to a converted row,
+// the same ones a task created through the API has to pass.
+func (s *Server) validateImportRow(task db.TaskModel) []ImportError {
+	var errs []ImportError
+	for _, violation := range s.Validator.Struct(TaskModelService{
+		Title:       task.Title,
+		Description: task.Description,
+		Status:      string(task.Status),
+		DueDate:     task.DueDate,
+	}) {
+		field, ok := taskCSVColumns[violation.Field]
+		if !ok {
+			field = violation.Field
+		}
+		errs = append(errs, ImportError{Field: field, Message: violation.Message})
+	}
+	return errs
+}
+
+// HandlerTasksImport creates tasks from a CSV body with the same columns as
+// the export. Query parameters: dry_run=true only validates, mode=atomic
+// (default) imports nothing if any row fails, mode=best_effort imports every
+// row it can.
+func (s *Server) HandlerTasksImport(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	dryRun := false
+	if dryRunStr := query.Get("dry_run"); dryRunStr != "" {
+		var err error
+		dryRun, err = strconv.ParseBool(dryRunStr)
+		if err != nil {
+			s.writeError(w, r, ErrBadRequest("bad dry_run specified", err, FieldError{Field: "dry_run", Message: "must be a boolean"}))
+			return
+		}
+	}
+
+	mode := query.Get("mode")
+	if mode == "" {
+		mode = ImportModeAtomic
+	}
+	if !IsValidImportMode(mode) {
+		s.writeError(w, r, ErrBadRequest("bad import mode specified", nil, FieldError{Field: "mode", Message: "must be atomic or best_effort"}))
+		return
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	var rows []TaskCSVRow
+	err = gocsv.UnmarshalBytes(body, &rows)
+	if err != nil {
+		s.writeError(w, r, ErrBadRequest("request body is not valid CSV", err))
+		return
+	}
+
+	userLogin, err := s.userLogin(r)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	summary := ImportSummary{
+		DryRun: dryRun,
+		Mode:   mode,
+		Total:  len(rows),
+		Ids:    []int64{},
+		Errors: []ImportError{},
+	}
+
+	tasks := make([]db.TaskModel, 0, len(rows))
+	taskRows := make([]int, 0, len(rows))
+	for i, row := range rows {
+		// Rows are numbered as in a spreadsheet: the header is row 1.
+		rowNumber := i + 2
+
+		task, errs := TaskCSVRowConvert(row)
+		if len(errs) == 0 {
+			errs = s.validateImportRow(task)
+		}
+		if len(errs) > 0 {
+			for _, e := range errs {
+				e.Row = rowNumber
+				summary.Errors = append(summary.Errors, e)
+			}
+			summary.Failed++
+			continue
+		}
+
+		tasks = append(tasks, task)
+		taskRows = append(taskRows, rowNumber)
+	}
+	summary.Valid = len(tasks)
+
+	status := http.StatusOK
+	switch {
+	case dryRun:
+	case mode == ImportModeAtomic && summary.Failed > 0:
+		status = http.StatusUnprocessableEntity
+	case mode == ImportModeAtomic:
+		ids, err := s.Db.TasksCreateMany(r.Context(), userLogin, tasks)
+		if err != nil {
+			s.writeError(w, r, err)
+			return
+		}
+		summary.Ids = ids
+		summary.Imported = len(ids)
+	case mode == ImportModeBestEffort:
+		for i, task := range tasks {
+			id, err := s.Db.TasksCreate(r.Context(), userLogin, task.Title, task.Description, string(task.Status), task.DueDate)
+			if err != nil {
+				apiErr := ErrorFromDb(r.Context(), err)
+				logAPIError(r.Context(), "import row failed", apiErr, "row", taskRows[i])
+				summary.Errors = append(summary.Errors, ImportError{Row: taskRows[i], Message: apiErr.Message})
+				summary.Failed++
+				continue
+			}
+			summary.Ids = append(summary.Ids, id)
+			summary.Imported++
+		}
+	}
+
+	writeJSON(w, status, summary)
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// LimitBody caps request bodies at MaxBodyBytes; reading past it fails and
+// readBody answers 413.
+func (s *Server) LimitBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > s.MaxBodyBytes {
+			s.writeError(w, r, &APIError{
+				Status:  http.StatusRequestEntityTooLarge,
+				Code:    ErrorCodeTooLarge,
+				Message: fmt.Sprintf("request body must be at most %d bytes", s.MaxBodyBytes),
+			})
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, s.MaxBodyBytes)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Deadline gives the request context the timeout of its route, or
+// RequestTimeout, so storage calls are cancelled once it passes. It must run
+// after routing.
+func (s *Server) Deadline(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout, ok := s.RouteTimeouts[routeTemplate(r)]
+		if !ok {
+			timeout = s.RequestTimeout
+		}
+		if timeout > 0 {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			r = r.WithContext(ctx)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// disableWriteTimeout lifts the server WriteTimeout for a streamed response,
+// which may rightly take longer than any fixed limit.
+func disableWriteTimeout(w http.ResponseWriter, r *http.Request) {
+	err := http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		slog.ErrorContext(r.Context(), "lifting write timeout", "error", err)
+	}
+}
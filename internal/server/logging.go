@@ -0,0 +1,130 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SetupLogger makes a JSON logger at LogLevel the default for slog and the
+// log package. Records logged with a request context get its request id and
+// trace id.
+func (s *Server) SetupLogger() error {
+	handler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: s.LogLevel})
+	slog.SetDefault(slog.New(contextHandler{handler}))
+
+	return nil
+}
+
+// contextHandler adds the request id and trace id of the context to every
+// record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestId := RequestIdFromContext(ctx); requestId != "" {
+		record.AddAttrs(slog.String("request_id", requestId))
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.HasTraceID() {
+		record.AddAttrs(slog.String("trace_id", spanContext.TraceID().String()))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// AccessLog logs every request once it is answered: method, path and route,
+// status, size, latency and the login of the access token if there is one.
+// It must run after RequestID.
+func (s *Server) AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", recorder.Status()),
+			slog.Int64("bytes", recorder.bytes),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+		}
+		if route := routeTemplate(r); route != "" {
+			attrs = append(attrs, slog.String("route", route))
+		}
+		if login, err := s.userLogin(r); err == nil {
+			attrs = append(attrs, slog.String("login", login))
+		}
+		slog.LogAttrs(r.Context(), slog.LevelInfo, "request", attrs...)
+	})
+}
+
+// routeTemplate is the path template of the mux route serving r, or "" if
+// no route matched.
+func routeTemplate(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return ""
+	}
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return ""
+	}
+	return template
+}
+
+// statusRecorder remembers the status and size of a response. Unwrap lets
+// http.NewResponseController reach the writer underneath.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Flush keeps streamed responses working, which check for http.Flusher.
+func (w *statusRecorder) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Status is the status sent, 200 if the handler wrote nothing at all.
+func (w *statusRecorder) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
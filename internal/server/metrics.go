@@ -0,0 +1,57 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"gitlab.com/vitbog/titov-rest/internal/db"
+	"gitlab.com/vitbog/titov-rest/internal/db/sqlite"
+	"gitlab.com/vitbog/titov-rest/internal/metrics"
+)
+
+// routeUnmatched labels requests no route matched, so unknown paths do not
+// each get a series of their own.
+const routeUnmatched = "unmatched"
+
+// SetupMetrics registers the metrics of the storage and wraps Db so that
+// every storage call is timed. It must run after SetupDb.
+func (s *Server) SetupMetrics() error {
+	m := metrics.New()
+
+	var err error
+	switch storage := s.Db.(type) {
+	case *db.Db:
+		err = m.RegisterDBStats("postgres", storage.Pg.DB)
+	case *sqlite.Store:
+		err = m.RegisterDBStats("sqlite", storage.Lite.DB)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = m.RegisterTasks(s.Db)
+	if err != nil {
+		return err
+	}
+
+	s.Metrics = m
+	s.Db = m.Storage(s.Db)
+
+	return nil
+}
+
+// InstrumentHTTP counts every request and its latency by method, route
+// template and status.
+func (s *Server) InstrumentHTTP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+
+		route := routeTemplate(r)
+		if route == "" {
+			route = routeUnmatched
+		}
+		s.Metrics.ObserveHTTP(r.Method, route, recorder.Status(), time.Since(start))
+	})
+}
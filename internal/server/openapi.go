@@ -0,0 +1,14 @@
+package server
+
+import (
+	"net/http"
+
+	"gitlab.com/vitbog/titov-rest/internal/openapi"
+)
+
+// HandlerOpenAPI serves the OpenAPI document of the API.
+func (s *Server) HandlerOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(openapi.Spec)
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+const RequestIdHeader = "X-Request-ID"
+
+const maxRequestIdLength = 128
+
+type requestIdKey struct{}
+
+// RequestID keeps the X-Request-ID the client sent, or assigns a new one,
+// and echoes it in the response so errors can be matched with the logs.
+func (s *Server) RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestId := r.Header.Get(RequestIdHeader)
+		if !requestIdIsValid(requestId) {
+			requestId = requestIdGenerate()
+		}
+
+		w.Header().Set(RequestIdHeader, requestId)
+		ctx := context.WithValue(r.Context(), requestIdKey{}, requestId)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func RequestIdFromContext(ctx context.Context) string {
+	requestId, _ := ctx.Value(requestIdKey{}).(string)
+	return requestId
+}
+
+func requestIdIsValid(candidate string) bool {
+	if candidate == "" || len(candidate) > maxRequestIdLength {
+		return false
+	}
+	for _, c := range candidate {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func requestIdGenerate() string {
+	raw := make([]byte, 16)
+	rand.Read(raw)
+	return hex.EncodeToString(raw)
+}
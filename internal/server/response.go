@@ -0,0 +1,60 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"gitlab.com/vitbog/titov-rest/internal/filters"
+)
+
+const (
+	PreferHeader            = "Prefer"
+	PreferenceAppliedHeader = "Preference-Applied"
+	PreferReturnMinimal     = "return=minimal"
+)
+
+// preferMinimal reports whether the client asked for an empty body with
+// "Prefer: return=minimal" (RFC 7240).
+func preferMinimal(r *http.Request) bool {
+	for _, header := range r.Header.Values(PreferHeader) {
+		for _, preference := range strings.Split(header, ",") {
+			token, _, _ := strings.Cut(preference, ";")
+			if strings.EqualFold(strings.ReplaceAll(token, " ", ""), PreferReturnMinimal) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// routePrefix returns the API version prefix the request came in on, so
+// links point back into the same version.
+func routePrefix(r *http.Request) string {
+	if r.URL.Path == APIV2Prefix || strings.HasPrefix(r.URL.Path, APIV2Prefix+"/") {
+		return APIV2Prefix
+	}
+	return ""
+}
+
+// queryFiltering parses filters.FromQuery parameters.
+func queryFiltering(r *http.Request) (filters.Filtering, error) {
+	filtering, err := filters.FromQuery(r.URL.Query())
+	if err != nil {
+		return filters.Filtering{}, ErrBadRequest("filtering is not valid", err)
+	}
+	return filtering, nil
+}
+
+// writeCreated answers 201 Created pointing at location, with the created
+// resource as the body unless the client prefers a minimal reply.
+func writeCreated(w http.ResponseWriter, r *http.Request, location string, v interface{}) {
+	w.Header().Set("Location", location)
+
+	if preferMinimal(r) {
+		w.Header().Set(PreferenceAppliedHeader, PreferReturnMinimal)
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, v)
+}
@@ -1,58 +1,196 @@
 package server
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"reflect"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/99designs/gqlgen/graphql/handler"
 	"gitlab.com/vitbog/titov-rest/internal/db"
+	"gitlab.com/vitbog/titov-rest/internal/db/memory"
+	"gitlab.com/vitbog/titov-rest/internal/db/sqlite"
+	"gitlab.com/vitbog/titov-rest/internal/events"
+	"gitlab.com/vitbog/titov-rest/internal/metrics"
+	"gitlab.com/vitbog/titov-rest/internal/migrate"
+	"gitlab.com/vitbog/titov-rest/internal/validate"
+	"google.golang.org/grpc"
 )
 
 type Server struct {
-	HTTP *http.Server
-	Db   *db.Db
+	HTTP      *http.Server
+	Db        db.Storage
+	Events    *events.Broker
+	Validator *validate.Validator
+	GRPC      *grpc.Server
+	GraphQL   *handler.Server
+	Metrics   *metrics.Metrics
 	Config
-}
 
-type Config struct {
-	JWTSecretKey  string
-	JWTAccessTime time.Duration
+	tracingShutdown func(context.Context) error
+
+	startedAt      time.Time
+	build          BuildInfo
+	migrator       *migrate.Migrator
+	workersStarted atomic.Int64
+	workersRunning atomic.Int64
+
+	stoppingOnce sync.Once
+	stoppingCh   chan struct{}
+	workers      sync.WaitGroup
 }
 
-func ConfigConvert(cfgFile ConfigFile) (Config, error) {
-	JWTAccessTime, err := time.ParseDuration(cfgFile.JWTAccessTime)
+// SetupDb opens the configured storage: Postgres, a SQLite file, or memory
+// for tests and demo mode.
+func (s *Server) SetupDb() error {
+	switch s.Storage {
+	case StorageMemory:
+		s.Db = memory.New()
+		return nil
+	case StorageSqlite:
+		lite, err := sqlite.New(s.SqlitePath)
+		if err != nil {
+			return err
+		}
+		s.Db = lite
+		return nil
+	}
+
+	pg, err := db.New(s.PgConnectionString, s.DbPool)
 	if err != nil {
-		return Config{}, err
+		return err
 	}
 
-	return Config{
-		JWTSecretKey:  cfgFile.JWTSecretKey,
-		JWTAccessTime: JWTAccessTime,
-	}, nil
-}
+	s.Db = pg
 
-type ConfigFile struct {
-	JWTSecretKey  string `json:"secret"`
-	JWTAccessTime string `json:"access_time"`
+	return nil
 }
 
-func (s *Server) SetupDb(pgConnectionString string) error {
-	db, err := db.New(pgConnectionString)
+func (s *Server) SetupEvents(pgConnectionString string) error {
+	broker, err := events.New(s.Db, pgConnectionString)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	s.Db = db
+	s.Events = broker
+
+	return nil
+}
+
+func (s *Server) SetupValidator() error {
+	v := validate.New()
+
+	notPast := validate.NotPast(time.Now)
+	if !s.DueDateNotInPast {
+		notPast = func(reflect.Value, string) (string, bool) { return "", true }
+	}
+	v.Register("notpast", notPast)
+
+	s.Validator = v
 
 	return nil
 }
 
 func (s *Server) SetupHTTP(serverAddress string, r http.Handler) error {
-	s.HTTP = &http.Server{Addr: serverAddress, Handler: r}
+	s.HTTP = &http.Server{
+		Addr:              serverAddress,
+		Handler:           r,
+		ReadTimeout:       s.ReadTimeout,
+		ReadHeaderTimeout: s.ReadHeaderTimeout,
+		WriteTimeout:      s.WriteTimeout,
+		IdleTimeout:       s.IdleTimeout,
+		MaxHeaderBytes:    s.MaxHeaderBytes,
+	}
 
 	return nil
 }
 
+// Run serves HTTP until Shutdown, when it returns http.ErrServerClosed.
 func (s *Server) Run() error {
 	err := s.HTTP.ListenAndServe()
 	return err
 }
+
+// Shutdown stops the servers gracefully: new connections are refused, event
+// streams and background workers are told to stop, and in-flight HTTP and
+// gRPC requests may finish until ctx is done. The Db pool is closed last.
+func (s *Server) Shutdown(ctx context.Context) error {
+	var errs []error
+
+	close(s.stopping())
+
+	grpcStopped := make(chan struct{})
+	if s.GRPC != nil {
+		go func() {
+			defer close(grpcStopped)
+			s.GRPC.GracefulStop()
+		}()
+	}
+
+	if s.HTTP != nil {
+		err := s.HTTP.Shutdown(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("http: %w", err))
+		}
+	}
+
+	if s.GRPC != nil {
+		select {
+		case <-grpcStopped:
+		case <-ctx.Done():
+			// Stop closes the remaining connections, which ends GracefulStop.
+			s.GRPC.Stop()
+			<-grpcStopped
+			errs = append(errs, fmt.Errorf("grpc: %w", ctx.Err()))
+		}
+	}
+
+	s.workers.Wait()
+
+	if s.Events != nil {
+		err := s.Events.Close()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("events: %w", err))
+		}
+	}
+	if s.Db != nil {
+		err := s.Db.Close()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("db: %w", err))
+		}
+	}
+	if s.tracingShutdown != nil {
+		err := s.tracingShutdown(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("tracing: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// stopping is closed when Shutdown starts. Long-running handlers and
+// background workers watch it.
+func (s *Server) stopping() chan struct{} {
+	s.stoppingOnce.Do(func() {
+		s.stoppingCh = make(chan struct{})
+	})
+	return s.stoppingCh
+}
+
+// background runs worker until Shutdown, which waits for it to return.
+func (s *Server) background(worker func(stopping <-chan struct{})) {
+	stopping := s.stopping()
+	s.workers.Add(1)
+	s.workersStarted.Add(1)
+	s.workersRunning.Add(1)
+	go func() {
+		defer s.workers.Done()
+		defer s.workersRunning.Add(-1)
+		worker(stopping)
+	}()
+}
@@ -0,0 +1,327 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"gitlab.com/vitbog/titov-rest/internal/db"
+)
+
+const (
+	SyncEntityTask    = "task"
+	SyncEntityComment = "comment"
+)
+
+const (
+	SyncActionCreate = "create"
+	SyncActionUpdate = "update"
+	SyncActionDelete = "delete"
+)
+
+const (
+	SyncStatusApplied  = db.SyncStatusApplied
+	SyncStatusConflict = db.SyncStatusConflict
+	SyncStatusNotFound = db.SyncStatusNotFound
+	SyncStatusError    = "error"
+)
+
+const syncTokenPrefix = "v1:"
+
+const syncCleanupPeriod = time.Hour
+
+type SyncTasksChanges struct {
+	Created []db.TaskModel `json:"created"`
+	Updated []db.TaskModel `json:"updated"`
+	Deleted []int64        `json:"deleted"`
+}
+
+type SyncCommentsChanges struct {
+	Created []db.CommentModel `json:"created"`
+	Updated []db.CommentModel `json:"updated"`
+	Deleted []int64           `json:"deleted"`
+}
+
+type SyncPullResponse struct {
+	Token    string              `json:"token"`
+	Tasks    SyncTasksChanges    `json:"tasks"`
+	Comments SyncCommentsChanges `json:"comments"`
+}
+
+type SyncChange struct {
+	ClientId      string              `json:"client_id"`
+	Entity        string              `json:"entity"`
+	Action        string              `json:"action"`
+	Id            int64               `json:"id"`
+	BaseUpdatedAt *time.Time          `json:"base_updated_at"`
+	Task          TaskModelService    `json:"task"`
+	Comment       CommentModelService `json:"comment"`
+	// TaskClientId lets a comment refer to a task created earlier in the
+	// same batch, before the client knows its server id.
+	TaskClientId string `json:"task_client_id"`
+}
+
+type SyncPushRequest struct {
+	Changes []SyncChange `json:"changes"`
+}
+
+type SyncResult struct {
+	ClientId string       `json:"client_id,omitempty"`
+	Entity   string       `json:"entity"`
+	Action   string       `json:"action"`
+	Id       int64        `json:"id,omitempty"`
+	Status   string       `json:"status"`
+	Error    string       `json:"error,omitempty"`
+	Details  []FieldError `json:"details,omitempty"`
+	Current  interface{}  `json:"current,omitempty"`
+}
+
+type SyncPushResponse struct {
+	Results []SyncResult `json:"results"`
+}
+
+func SyncTokenEncode(t time.Time) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(syncTokenPrefix + t.Format(time.RFC3339Nano)))
+}
+
+func SyncTokenDecode(tok string) (time.Time, error) {
+	if tok == "" {
+		return time.Time{}, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(tok)
+	if err != nil {
+		return time.Time{}, errors.New("sync token is invalid")
+	}
+	str, ok := strings.CutPrefix(string(raw), syncTokenPrefix)
+	if !ok {
+		return time.Time{}, errors.New("sync token version is not supported")
+	}
+
+	return time.Parse(time.RFC3339Nano, str)
+}
+
+// HandlerSyncPull returns everything changed after the since token together
+// with the token for the next pull. An empty token means a full sync. The
+// token points SyncOverlap before the pull, so changes of transactions still
+// running during it are sent by the next pull; changes sent again are the same
+// rows and apply the same way. A token older than SyncRetention is refused
+// with 410, as the tombstones it needs may be gone.
+func (s *Server) HandlerSyncPull(w http.ResponseWriter, r *http.Request) {
+	since, err := SyncTokenDecode(r.URL.Query().Get("since"))
+	if err != nil {
+		s.writeError(w, r, ErrBadRequest("sync token is not valid", err, FieldError{Field: "since", Message: "is not a sync token"}))
+		return
+	}
+
+	until, err := s.Db.SyncNow(r.Context())
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	if !since.IsZero() && since.Before(until.Add(-s.SyncRetention)) {
+		s.writeError(w, r, ErrResyncRequired())
+		return
+	}
+
+	tasks, err := s.Db.SyncTasks(r.Context(), since, until)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	comments, err := s.Db.SyncComments(r.Context(), since, until)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	tombstones, err := s.Db.SyncTombstones(r.Context(), since, until)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	response := SyncPullResponse{
+		Token:    SyncTokenEncode(until.Add(-s.SyncOverlap)),
+		Tasks:    SyncTasksChanges{Created: []db.TaskModel{}, Updated: []db.TaskModel{}, Deleted: []int64{}},
+		Comments: SyncCommentsChanges{Created: []db.CommentModel{}, Updated: []db.CommentModel{}, Deleted: []int64{}},
+	}
+	for _, t := range tasks {
+		if t.CreatedAt.After(since) {
+			response.Tasks.Created = append(response.Tasks.Created, t)
+		} else {
+			response.Tasks.Updated = append(response.Tasks.Updated, t)
+		}
+	}
+	for _, c := range comments {
+		if c.CreatedAt.After(since) {
+			response.Comments.Created = append(response.Comments.Created, c)
+		} else {
+			response.Comments.Updated = append(response.Comments.Updated, c)
+		}
+	}
+	for _, t := range tombstones {
+		switch t.Entity {
+		case SyncEntityTask:
+			response.Tasks.Deleted = append(response.Tasks.Deleted, t.IdEntity)
+		case SyncEntityComment:
+			response.Comments.Deleted = append(response.Comments.Deleted, t.IdEntity)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// SetupSync starts removing tombstones older than SyncRetention in the
+// background.
+func (s *Server) SetupSync() error {
+	s.background(func(stopping <-chan struct{}) {
+		ticker := time.NewTicker(syncCleanupPeriod)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stopping:
+				return
+			case <-ticker.C:
+				// Tombstones are stamped by the storage clock, as tokens are.
+				now, err := s.Db.SyncNow(context.Background())
+				if err == nil {
+					err = s.Db.SyncTombstonesCleanup(context.Background(), now.Add(-s.SyncRetention))
+				}
+				if err != nil {
+					slog.Error("sync tombstones cleanup", "error", err)
+				}
+			}
+		}
+	})
+
+	return nil
+}
+
+// HandlerSyncPush applies client changes in order. Updates and deletes carry
+// the updated_at the client based them on; if the task changed on the server
+// since then, the change is reported as a conflict along with the current
+// server version and is not applied.
+func (s *Server) HandlerSyncPush(w http.ResponseWriter, r *http.Request) {
+	var request SyncPushRequest
+	err := decodeJSON(r, &request)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	userLogin, err := s.userLogin(r)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	createdTasks := make(map[string]int64)
+	response := SyncPushResponse{Results: make([]SyncResult, 0, len(request.Changes))}
+	for _, change := range request.Changes {
+		result := s.syncApply(r, userLogin, change, createdTasks)
+		response.Results = append(response.Results, result)
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (s *Server) syncApply(r *http.Request, userLogin string, change SyncChange, createdTasks map[string]int64) SyncResult {
+	result := SyncResult{
+		ClientId: change.ClientId,
+		Entity:   change.Entity,
+		Action:   change.Action,
+		Id:       change.Id,
+	}
+	fail := func(err error) SyncResult {
+		apiErr := ErrorFromDb(r.Context(), err)
+		logAPIError(r.Context(), "sync change failed", apiErr, "client_id", change.ClientId)
+		result.Status = SyncStatusError
+		result.Error = apiErr.Message
+		result.Details = apiErr.Details
+		return result
+	}
+
+	switch change.Entity {
+	case SyncEntityTask:
+		task := change.Task
+		switch change.Action {
+		case SyncActionCreate:
+			err := s.validate(task)
+			if err != nil {
+				return fail(err)
+			}
+			id, err := s.Db.TasksCreate(r.Context(), userLogin, task.Title, task.Description, task.Status, task.DueDate)
+			if err != nil {
+				return fail(err)
+			}
+			if change.ClientId != "" {
+				createdTasks[change.ClientId] = id
+			}
+			result.Id = id
+			result.Status = SyncStatusApplied
+		case SyncActionUpdate:
+			err := s.validateTaskUpdate(r.Context(), change.Id, task)
+			if errors.Is(err, db.ErrNotFound) {
+				result.Status = SyncStatusNotFound
+				return result
+			}
+			if err != nil {
+				return fail(err)
+			}
+			status, err := s.Db.SyncTaskUpdate(r.Context(), change.Id, task.Title, task.Description, task.Status, task.DueDate, change.BaseUpdatedAt)
+			if err != nil {
+				return fail(err)
+			}
+			result.Status = status
+		case SyncActionDelete:
+			status, err := s.Db.SyncTaskDelete(r.Context(), change.Id, change.BaseUpdatedAt)
+			if err != nil {
+				return fail(err)
+			}
+			result.Status = status
+		default:
+			return fail(ErrBadRequest("sync action is not valid", nil))
+		}
+
+		if result.Status == SyncStatusConflict {
+			current, err := s.Db.Task(r.Context(), change.Id)
+			if err != nil {
+				return fail(err)
+			}
+			result.Current = current
+		}
+	case SyncEntityComment:
+		if change.Action != SyncActionCreate {
+			return fail(ErrBadRequest("comments can only be created", nil))
+		}
+		err := s.validate(change.Comment)
+		if err != nil {
+			return fail(err)
+		}
+		taskId := change.Comment.IdTask
+		if change.TaskClientId != "" {
+			id, ok := createdTasks[change.TaskClientId]
+			if !ok {
+				return fail(ErrBadRequest("task_client_id does not refer to a task created in this batch", nil))
+			}
+			taskId = id
+		}
+		id, err := s.Db.CommentCreate(r.Context(), taskId, userLogin, change.Comment.Content)
+		if err != nil {
+			return fail(err)
+		}
+		result.Id = id
+		result.Status = SyncStatusApplied
+	default:
+		return fail(ErrBadRequest("sync entity is not valid", nil))
+	}
+
+	return result
+}
@@ -1,31 +1,31 @@
 package server
 
 import (
-	"encoding/json"
-	"io"
-	"log"
+	"context"
+	"fmt"
 	"net/http"
-	"strconv"
 	"time"
 
-	"github.com/gocarina/gocsv"
-	"github.com/gorilla/mux"
 	"gitlab.com/vitbog/titov-rest/internal/db"
+	"gitlab.com/vitbog/titov-rest/internal/export"
 	"gitlab.com/vitbog/titov-rest/internal/filters"
-	"gitlab.com/vitbog/titov-rest/internal/token"
 )
 
 type TaskModelService struct {
 	Id          int64     `json:"id" db:"id"`
 	IdUser      int64     `json:"id_user" db:"id_user"`
-	Title       string    `json:"title" db:"title"`
-	Description string    `json:"description" db:"description"`
-	Status      string    `json:"status" db:"status"`
+	Title       string    `json:"title" db:"title" validate:"required,max=200"`
+	Description string    `json:"description" db:"description" validate:"max=5000"`
+	Status      string    `json:"status" db:"status" validate:"required,oneof=frozen pending in-progress completed"`
 	CreatedAt   time.Time `json:"created_at" db:"created_at"`
-	DueDate     time.Time `json:"due_date" db:"due_date"`
+	DueDate     time.Time `json:"due_date" db:"due_date" validate:"required,notpast"`
 	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
 }
 
+func TaskURL(taskId int64) string {
+	return fmt.Sprintf("/tasks/%d", taskId)
+}
+
 func (s *Server) TaskModelServiceConvertFromModel(t db.TaskModel) (TaskModelService, error) {
 	return TaskModelService{
 		Id:          t.Id,
@@ -54,172 +54,256 @@ func (s *Server) TasksModelServiceConvertFromModel(tasks []db.TaskModel) ([]Task
 }
 
 func (s *Server) HandlerTasksCreate(w http.ResponseWriter, r *http.Request) {
-	body, err := io.ReadAll(r.Body)
+	var task TaskModelService
+	err := decodeJSON(r, &task)
 	if err != nil {
-		log.Printf("Error: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		s.writeError(w, r, err)
 		return
 	}
 
-	var task TaskModelService
-	err = json.Unmarshal(body, &task)
+	err = s.validate(task)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	userLogin, err := s.userLogin(r)
 	if err != nil {
-		log.Printf("Error: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		s.writeError(w, r, err)
 		return
 	}
 
-	tok := r.Header.Get("Authorization")
-	userLoginInterface, err := token.Field("login", tok, s.JWTSecretKey)
-	userLogin, ok := userLoginInterface.(string)
-	if !ok {
-		log.Printf("Error: %s", "bad login in token")
-		w.WriteHeader(http.StatusBadRequest)
+	taskId, err := s.Db.TasksCreate(r.Context(), userLogin, task.Title, task.Description, task.Status, task.DueDate)
+	if err != nil {
+		s.writeError(w, r, err)
 		return
 	}
 
-	_, err = s.Db.TasksCreate(userLogin, task.Title, task.Description, task.Status, task.DueDate)
+	created, err := s.Db.Task(r.Context(), taskId)
 	if err != nil {
-		log.Printf("Error: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		s.writeError(w, r, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	writeCreated(w, r, routePrefix(r)+TaskURL(taskId), created)
 }
 
-func (s *Server) HandlerTasks(w http.ResponseWriter, r *http.Request) {
-	body, err := io.ReadAll(r.Body)
+func (s *Server) HandlerTask(w http.ResponseWriter, r *http.Request) {
+	taskId, err := pathId(r, "id_task")
 	if err != nil {
-		log.Printf("Error: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		s.writeError(w, r, err)
 		return
 	}
 
-	var filtering filters.Filtering
-	err = json.Unmarshal(body, &filtering)
+	task, err := s.Db.Task(r.Context(), taskId)
 	if err != nil {
-		log.Printf("Error: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		s.writeError(w, r, err)
 		return
 	}
 
-	tasks, err := s.Db.Tasks(filtering)
+	writeJSON(w, http.StatusOK, task)
+}
+
+func (s *Server) HandlerTasks(w http.ResponseWriter, r *http.Request) {
+	var filtering filters.Filtering
+	err := decodeJSON(r, &filtering)
 	if err != nil {
-		log.Printf("Error: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		s.writeError(w, r, err)
 		return
 	}
 
-	result, err := json.Marshal(tasks)
+	tasks, err := s.Db.Tasks(r.Context(), filtering)
 	if err != nil {
-		log.Printf("Error: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		s.writeError(w, r, err)
 		return
 	}
 
 	if len(tasks) == 0 {
 		w.WriteHeader(http.StatusNoContent)
+		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(result)
-	w.WriteHeader(http.StatusOK)
+	writeJSON(w, http.StatusOK, tasks)
 }
 
+// HandlerTasksQuery lists the tasks matching the query string, see
+// filters.FromQuery. Unlike HandlerTasks, no match is an empty list.
+func (s *Server) HandlerTasksQuery(w http.ResponseWriter, r *http.Request) {
+	filtering, err := queryFiltering(r)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	tasks, err := s.Db.Tasks(r.Context(), filtering)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tasks)
+}
+
+// HandlerTasksCSV streams the tasks matching the filtering body as CSV. The
+// output is shaped by query parameters, see export.ParseOptions.
 func (s *Server) HandlerTasksCSV(w http.ResponseWriter, r *http.Request) {
-	body, err := io.ReadAll(r.Body)
+	s.exportTasks(w, r, export.FormatCSV)
+}
+
+// validateTaskUpdate is validate for a full update of the task taskId. Like
+// a PATCH, the due date is only checked when it changes, so an overdue task
+// can still be edited without moving its due date. A missing task is
+// reported as db.ErrNotFound before anything is validated.
+func (s *Server) validateTaskUpdate(ctx context.Context, taskId int64, task TaskModelService) error {
+	current, err := s.Db.Task(ctx, taskId)
+	if err != nil {
+		return err
+	}
+
+	if task.DueDate.Equal(current.DueDate) {
+		return s.validateFields(task, "title", "description", "status")
+	}
+	return s.validate(task)
+}
+
+func (s *Server) HandlerTasksUpdate(w http.ResponseWriter, r *http.Request) {
+	var task TaskModelService
+	err := decodeJSON(r, &task)
 	if err != nil {
-		log.Printf("Error: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		s.writeError(w, r, err)
 		return
 	}
 
-	var filtering filters.Filtering
-	err = json.Unmarshal(body, &filtering)
+	taskId, err := pathId(r, "id_task")
 	if err != nil {
-		log.Printf("Error: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		s.writeError(w, r, err)
 		return
 	}
 
-	tasks, err := s.Db.Tasks(filtering)
+	err = s.validateTaskUpdate(r.Context(), taskId, task)
 	if err != nil {
-		log.Printf("Error: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		s.writeError(w, r, err)
 		return
 	}
 
-	if len(tasks) == 0 {
-		w.WriteHeader(http.StatusNoContent)
+	err = s.Db.TasksUpdate(r.Context(), taskId, task.Title, task.Description, task.Status, task.DueDate)
+	if err != nil {
+		s.writeError(w, r, err)
 		return
 	}
 
-	w.Header().Add("Content-Disposition", `attachment; filename="test.csv"`)
-	gocsv.Marshal(tasks, w)
 	w.WriteHeader(http.StatusOK)
 }
 
-func (s *Server) HandlerTasksUpdate(w http.ResponseWriter, r *http.Request) {
-	body, err := io.ReadAll(r.Body)
+// TaskPatch holds the fields of a partial update; absent fields are kept.
+type TaskPatch struct {
+	Title       *string    `json:"title"`
+	Description *string    `json:"description"`
+	Status      *string    `json:"status"`
+	DueDate     *time.Time `json:"due_date"`
+}
+
+func (s *Server) HandlerTasksPatch(w http.ResponseWriter, r *http.Request) {
+	var patch TaskPatch
+	err := decodeJSON(r, &patch)
 	if err != nil {
-		log.Printf("Error: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		s.writeError(w, r, err)
 		return
 	}
 
-	var task TaskModelService
-	err = json.Unmarshal(body, &task)
+	taskId, err := pathId(r, "id_task")
 	if err != nil {
-		log.Printf("Error: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		s.writeError(w, r, err)
 		return
 	}
 
-	taskIdStr, ok := mux.Vars(r)["id_task"]
-	if !ok {
-		log.Printf("Error: %s", "no id specified")
-		w.WriteHeader(http.StatusBadRequest)
+	current, err := s.Db.Task(r.Context(), taskId)
+	if err != nil {
+		s.writeError(w, r, err)
 		return
 	}
-	taskId, err := strconv.ParseInt(taskIdStr, 10, 64)
+
+	task, err := s.TaskModelServiceConvertFromModel(current)
 	if err != nil {
-		log.Printf("Error: %s", "bad id specified")
-		w.WriteHeader(http.StatusBadRequest)
+		s.writeError(w, r, ErrInternal(err))
 		return
 	}
 
-	err = s.Db.TasksUpdate(taskId, task.Title, task.Description, task.Status, task.DueDate)
+	var patched []string
+	if patch.Title != nil {
+		task.Title = *patch.Title
+		patched = append(patched, "title")
+	}
+	if patch.Description != nil {
+		task.Description = *patch.Description
+		patched = append(patched, "description")
+	}
+	if patch.Status != nil {
+		task.Status = *patch.Status
+		patched = append(patched, "status")
+	}
+	if patch.DueDate != nil {
+		task.DueDate = *patch.DueDate
+		patched = append(patched, "due_date")
+	}
+
+	err = s.validateFields(task, patched...)
 	if err != nil {
-		log.Printf("Error: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		s.writeError(w, r, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	err = s.Db.TasksUpdate(r.Context(), taskId, task.Title, task.Description, task.Status, task.DueDate)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	updated, err := s.Db.Task(r.Context(), taskId)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, updated)
 }
 
-func (s *Server) HandlerTasksDelete(w http.ResponseWriter, r *http.Request) {
-	body, err := io.ReadAll(r.Body)
+// HandlerTaskDelete deletes the task in the path, unlike HandlerTasksDelete
+// which takes a list of ids in the body.
+func (s *Server) HandlerTaskDelete(w http.ResponseWriter, r *http.Request) {
+	taskId, err := pathId(r, "id_task")
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	_, err = s.Db.Task(r.Context(), taskId)
 	if err != nil {
-		log.Printf("Error: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		s.writeError(w, r, err)
 		return
 	}
 
+	err = s.Db.TasksDelete(r.Context(), []int64{taskId})
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) HandlerTasksDelete(w http.ResponseWriter, r *http.Request) {
 	var Ids struct {
 		Ids []int64 `json:"ids"`
 	}
-	err = json.Unmarshal(body, &Ids)
+	err := decodeJSON(r, &Ids)
 	if err != nil {
-		log.Printf("Error: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		s.writeError(w, r, err)
 		return
 	}
 
-	err = s.Db.TasksDelete(Ids.Ids)
+	err = s.Db.TasksDelete(r.Context(), Ids.Ids)
 	if err != nil {
-		log.Printf("Error: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		s.writeError(w, r, err)
 		return
 	}
 
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"gitlab.com/vitbog/titov-rest/internal/tracing"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SetupTracing exports spans to TracingExporter, see tracing.Setup.
+func (s *Server) SetupTracing() error {
+	shutdown, err := tracing.Setup(context.Background(), s.TracingExporter, s.TracingEndpoint)
+	if err != nil {
+		return err
+	}
+
+	s.tracingShutdown = shutdown
+
+	return nil
+}
+
+// Trace starts a span for every request, named after its route template and
+// continuing the trace of an incoming traceparent header. Storage calls made
+// with the request context become its children.
+func (s *Server) Trace(next http.Handler) http.Handler {
+	routed := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route := routeTemplate(r); route != "" {
+			trace.SpanFromContext(r.Context()).SetAttributes(semconv.HTTPRoute(route))
+		}
+		next.ServeHTTP(w, r)
+	})
+
+	return otelhttp.NewHandler(routed, "http",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			if route := routeTemplate(r); route != "" {
+				return r.Method + " " + route
+			}
+			return r.Method
+		}))
+}
@@ -0,0 +1,70 @@
+// Package tracing sets up OpenTelemetry: the tracer provider exporting the
+// spans and the W3C trace context propagation of incoming requests.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+)
+
+const ServiceName = "titov-rest"
+
+// Exporters accepted by Setup.
+const (
+	// ExporterNone keeps spans in the process: trace ids are still
+	// propagated but nothing is sent.
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// Setup installs the global tracer provider and propagator. Spans go to the
+// exporter: stdout writes them as JSON, OTLP sends them over HTTP to
+// endpoint, a collector such as localhost:4318, or to the OTEL_EXPORTER_OTLP_*
+// environment variables when endpoint is empty. The returned shutdown flushes
+// the spans not yet exported.
+func Setup(ctx context.Context, exporter, endpoint string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(ServiceName)))
+	if err != nil {
+		return nil, err
+	}
+	options := []sdktrace.TracerProviderOption{sdktrace.WithResource(res)}
+
+	switch exporter {
+	case ExporterNone:
+	case ExporterStdout:
+		spanExporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, sdktrace.WithBatcher(spanExporter))
+	case ExporterOTLP:
+		var otlpOptions []otlptracehttp.Option
+		if endpoint != "" {
+			otlpOptions = append(otlpOptions, otlptracehttp.WithEndpoint(endpoint), otlptracehttp.WithInsecure())
+		}
+		spanExporter, err := otlptracehttp.New(ctx, otlpOptions...)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, sdktrace.WithBatcher(spanExporter))
+	default:
+		return nil, fmt.Errorf("unknown exporter %q", exporter)
+	}
+
+	provider := sdktrace.NewTracerProvider(options...)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
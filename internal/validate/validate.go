@@ -0,0 +1,174 @@
+package validate
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const TagName = "validate"
+
+// Violation is one failed rule. Field is the JSON name of the field.
+type Violation struct {
+	Field   string
+	Message string
+}
+
+// Rule checks a field value against the rule parameter (the text after "="
+// in the tag, if any) and returns a message when the value is not valid.
+type Rule func(value reflect.Value, param string) (string, bool)
+
+// Validator checks structs against their `validate` tags, e.g.
+//
+//	Title string `json:"title" validate:"required,max=200"`
+//
+// Built-in rules:
+//
+//	required   non-zero value: non-blank string, non-zero number or time
+//	min=N      string length in characters or number value of at least N
+//	max=N      string length in characters or number value of at most N
+//	oneof=a b  value is one of the space separated options
+//
+// Further rules are added with Register.
+type Validator struct {
+	rules map[string]Rule
+}
+
+func New() *Validator {
+	v := &Validator{rules: make(map[string]Rule)}
+	v.Register("required", required)
+	v.Register("min", bound(true))
+	v.Register("max", bound(false))
+	v.Register("oneof", oneof)
+	return v
+}
+
+func (v *Validator) Register(name string, rule Rule) {
+	v.rules[name] = rule
+}
+
+// Struct validates every tagged field of s, which must be a struct or a
+// pointer to one, and returns all violations at once.
+func (v *Validator) Struct(s interface{}) []Violation {
+	value := reflect.Indirect(reflect.ValueOf(s))
+	if value.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("validate: %T is not a struct", s))
+	}
+
+	var violations []Violation
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		tag, ok := field.Tag.Lookup(TagName)
+		if !ok || tag == "" {
+			continue
+		}
+
+		name := fieldName(field)
+		fieldValue := value.Field(i)
+		for _, ruleSpec := range strings.Split(tag, ",") {
+			ruleName, param, _ := strings.Cut(ruleSpec, "=")
+			rule, ok := v.rules[ruleName]
+			if !ok {
+				panic(fmt.Sprintf("validate: unknown rule %q on %s.%s", ruleName, valueType.Name(), field.Name))
+			}
+
+			message, ok := rule(fieldValue, param)
+			if !ok {
+				violations = append(violations, Violation{Field: name, Message: message})
+				// Further rules would only repeat the problem, e.g. max
+				// after a failed required.
+				break
+			}
+		}
+	}
+
+	return violations
+}
+
+func fieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+func required(value reflect.Value, param string) (string, bool) {
+	switch v := value.Interface().(type) {
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return "is required", false
+		}
+		return "", true
+	case time.Time:
+		if v.IsZero() {
+			return "is required", false
+		}
+		return "", true
+	}
+
+	if value.IsZero() {
+		return "is required", false
+	}
+	return "", true
+}
+
+func bound(isMin bool) Rule {
+	return func(value reflect.Value, param string) (string, bool) {
+		limit, err := strconv.ParseInt(param, 10, 64)
+		if err != nil {
+			panic(fmt.Sprintf("validate: bad bound %q", param))
+		}
+
+		var actual int64
+		unit := ""
+		switch value.Kind() {
+		case reflect.String:
+			actual = int64(utf8.RuneCountInString(value.String()))
+			unit = " characters"
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			actual = value.Int()
+		case reflect.Slice:
+			actual = int64(value.Len())
+			unit = " items"
+		default:
+			panic(fmt.Sprintf("validate: min/max on unsupported kind %s", value.Kind()))
+		}
+
+		if isMin && actual < limit {
+			return fmt.Sprintf("must be at least %d%s", limit, unit), false
+		}
+		if !isMin && actual > limit {
+			return fmt.Sprintf("must be at most %d%s", limit, unit), false
+		}
+		return "", true
+	}
+}
+
+func oneof(value reflect.Value, param string) (string, bool) {
+	options := strings.Fields(param)
+	if !slices.Contains(options, fmt.Sprint(value.Interface())) {
+		return fmt.Sprintf("must be one of: %s", strings.Join(options, ", ")), false
+	}
+	return "", true
+}
+
+// NotPast builds a rule rejecting times before now(). Zero times pass; pair
+// it with required to reject them.
+func NotPast(now func() time.Time) Rule {
+	return func(value reflect.Value, param string) (string, bool) {
+		t, ok := value.Interface().(time.Time)
+		if !ok {
+			panic(fmt.Sprintf("validate: notpast on %s", value.Type()))
+		}
+		if !t.IsZero() && t.Before(now()) {
+			return "must not be in the past", false
+		}
+		return "", true
+	}
+}
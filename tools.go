@@ -0,0 +1,8 @@
+//go:build tools
+
+// Package tools pins the code generators so go.mod records their versions.
+package tools
+
+import (
+	_ "github.com/99designs/gqlgen"
+)